@@ -1,31 +1,54 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"math/rand"
 	"os"
 	"os/signal"
+	"strings"
+	"sync/atomic"
 	"syscall"
+	"time"
+	"werewolves-go/network"
 	"werewolves-go/types"
 
 	"github.com/anthdm/hollywood/actor"
-	"github.com/anthdm/hollywood/remote"
+	"github.com/gdamore/tcell/v2"
 )
 
 type client struct {
 	username  string
+	room      string
+	token     string
+	silence   time.Duration
+	watchdog  *time.Timer
+	lost      atomic.Bool
+	renames   chan<- struct{}
 	serverPID *actor.PID
+	out       terminal
 	logger    *slog.Logger
 }
 
-func newClient(username string, serverPID *actor.PID) actor.Producer {
+/*
+ * A client given a session token takes back its seat instead of joining a
+ * room. Once the server sent a heartbeat, the user is warned whenever it
+ * stays silent for longer than silence. A refused username is reported on
+ * renames, the next line typed is then the new one. Everything the client
+ * hears is shown on out.
+ */
+func newClient(username string, room string, token string, silence time.Duration, renames chan<- struct{}, serverPID *actor.PID, out terminal) actor.Producer {
 	return func() actor.Receiver {
 		return &client{
 			username:  username,
+			room:      room,
+			token:     token,
+			silence:   silence,
+			renames:   renames,
 			serverPID: serverPID,
+			out:       out,
 			logger:    slog.Default(),
 		}
 	}
@@ -34,16 +57,165 @@ func newClient(username string, serverPID *actor.PID) actor.Producer {
 func (c *client) Receive(ctx *actor.Context) {
 	switch msg := ctx.Message().(type) {
 	case *types.Message:
-		fmt.Printf("%s: %s\n", msg.Username, msg.Msg)
+		var channel string
+		if msg.Channel != "" && msg.Channel != "town" {
+			channel = fmt.Sprintf("[%s] ", msg.Channel)
+		}
+		c.out.Printf("%s%s: %s\n", channel, msg.Username, msg.Msg)
+	case *types.RoomList:
+		if len(msg.Rooms) == 0 {
+			c.out.Printf("No rooms yet. Type /create <room> to create one.\n")
+		}
+		for _, room := range msg.Rooms {
+			var deck string
+			if room.Deck != "" {
+				deck = ", deck " + room.Deck
+			}
+			c.out.Printf("room %s: %d players, %s%s\n", room.Id, room.Players, room.State, deck)
+		}
+	case *types.PhaseChanged:
+		deadline := time.UnixMilli(msg.Deadline)
+		c.out.Phase(msg.Phase, deadline)
+		c.out.Printf("---------- %s until %s ----------\n", msg.Phase, deadline.Format(time.TimeOnly))
+	case *types.RoleAssigned:
+		c.out.Role(msg.Role)
+		c.out.Printf("========== You are a %s =========\n", msg.Role)
+	case *types.PlayerList:
+		c.out.Players(msg.Players)
+	case *types.PlayerEliminated:
+		c.out.Printf("%s was eliminated\n", msg.Username)
+	case *types.VoteResult:
+		var votes []string
+		for _, count := range msg.Votes {
+			votes = append(votes, fmt.Sprintf("%s %d", count.Username, count.Votes))
+		}
+		c.out.Printf("Votes: %s\n", strings.Join(votes, ", "))
+	case *types.GameOver:
+		c.out.Printf("Game over: %s\n", msg.Winner)
+	case *types.PresenceChanged:
+		switch msg.Presence {
+		case "away":
+			c.out.Printf("%s is not responding\n", msg.Username)
+		case "offline":
+			c.out.Printf("%s lost their connection, their seat is kept until they come back\n", msg.Username)
+		default:
+			c.out.Printf("%s is back\n", msg.Username)
+		}
+	case *types.Heartbeat:
+		ctx.Send(c.serverPID, &types.Heartbeat{})
+		if c.lost.Swap(false) {
+			c.out.Printf("The server is responding again.\n")
+		}
+		if c.watchdog == nil {
+			c.watchdog = time.AfterFunc(c.silence, func() {
+				c.lost.Store(true)
+				c.out.Printf("The server is not responding. Start again with your session token if it does not come back.\n")
+			})
+		} else {
+			c.watchdog.Reset(c.silence)
+		}
+	case *types.ErrorReply:
+		c.out.Printf("error: %s\n", msg.Msg)
+	case *types.UsernameRejected:
+		c.out.Printf("error: %s\n", msg.Reason)
+		c.out.Printf("Please enter another username: \n")
+		select {
+		case c.renames <- struct{}{}:
+		default:
+		}
+	case *types.Session:
+		c.out.Joined(msg.Room)
+		c.out.Printf("You joined room %s. If you lose your connection, start again with -token %s to get your seat back.\n", msg.Room, msg.Token)
+	case *types.RoomLeft:
+		// Rooms check on their players only, the server stops sending heartbeats.
+		if c.watchdog != nil {
+			c.watchdog.Stop()
+			c.watchdog = nil
+		}
+		c.out.Joined("")
+		c.out.Role("")
+		c.out.Phase("", time.Time{})
+		c.out.Players(nil)
+		c.out.Printf("You left room %s. Type /join <room> or /create <room> to play again.\n", msg.Room)
 	case actor.Started:
+		if c.token != "" {
+			ctx.Send(c.serverPID, &types.Reconnect{Token: c.token})
+			return
+		}
 		ctx.Send(c.serverPID, &types.Connect{
 			Username: c.username,
+			Room:     c.room,
 		})
 	case actor.Stopped:
+		if c.watchdog != nil {
+			c.watchdog.Stop()
+		}
 		c.logger.Info("client stopped")
 	}
 }
 
+// Commands understood by the client, printed by /help.
+const commands = `/rooms                   list the rooms of the server
+/create <room> [deck]    open a room, optionally choosing its roles
+/join <room>             enter an existing room
+/leave                   leave your room between games
+/vote <name>             vote against a player
+/heal <name>, /pass      save the werewolves' victim or pass, as the witch
+/poison <name>           poison a player, as the witch
+/inspect <name>          learn whether a player is a werewolf, as the seer
+/protect <name>          watch over a player tonight, as the bodyguard
+/shoot <name>            take a player down with you, as a dying hunter
+/link <first> <second>   make two players fall in love, as cupid
+/rematch                 ask for a new game once it is over
+anything else            chat with the players who can hear you`
+
+// Turns a line typed by the user into the message sent to the server.
+// Lines starting with a slash are commands, anything else is chat. It
+// returns nil for lines handled by the client itself.
+func parseInput(text string, out terminal) any {
+	command, arg, _ := strings.Cut(text, " ")
+	arg = strings.TrimSpace(arg)
+	switch command {
+	case "/help":
+		out.Printf("%s\n", commands)
+		return nil
+	case "/rooms":
+		return &types.ListRooms{}
+	case "/create":
+		room, deck, _ := strings.Cut(arg, " ")
+		return &types.CreateRoom{Room: room, Deck: strings.TrimSpace(deck)}
+	case "/join":
+		return &types.JoinRoom{Room: arg}
+	case "/leave":
+		return &types.LeaveRoom{}
+	case "/vote":
+		return &types.CastVote{Target: arg}
+	case "/heal":
+		return &types.UseHeal{Target: arg}
+	case "/pass":
+		return &types.UseHeal{}
+	case "/poison":
+		return &types.UsePoison{Target: arg}
+	case "/inspect":
+		return &types.InspectPlayer{Target: arg}
+	case "/protect":
+		return &types.ProtectPlayer{Target: arg}
+	case "/shoot":
+		return &types.ShootPlayer{Target: arg}
+	case "/link":
+		names := strings.FieldsFunc(arg, func(r rune) bool { return r == ',' || r == ' ' })
+		if len(names) != 2 {
+			out.Printf("error: name exactly two players, e.g. /link a b\n")
+			return nil
+		}
+		return &types.LinkLovers{First: names[0], Second: names[1]}
+	case "/rematch":
+		return &types.RequestRematch{}
+	}
+
+	return &types.Message{Msg: text}
+}
+
 // Handles keyboard interrupts from the client
 // https://www.gnu.org/software/libc/manual/html_node/Termination-Signals.html
 func getFireSignalsChannel() chan os.Signal {
@@ -66,9 +238,15 @@ func cleanup(serverPID *actor.PID, clientPID *actor.PID, e *actor.Engine) {
 
 func main() {
 	var (
-		listenAt  = flag.String("listen", "", "specify address to listen to, will pick a random port if not specified")
-		connectTo = flag.String("connect", "127.0.0.1:4000", "the address of the server to connect to")
-		username  = flag.String("username", "", "Enter username for client")
+		listenAt   = flag.String("listen", "", "specify address to listen to, will pick a random port if not specified")
+		advertise  = flag.String("advertise", "", "host:port the server reaches the client at, when it differs from the listen address")
+		connectTo  = flag.String("connect", "127.0.0.1:4000", "the address of the server to connect to")
+		username   = flag.String("username", "", "Enter username for client")
+		room       = flag.String("room", "main", "the room to join, created if it does not exist yet")
+		token      = flag.String("token", "", "session token of a game to get back into after losing the connection")
+		silence    = flag.Duration("server-timeout", 30*time.Second, "time without heartbeat after which the server is taken for gone")
+		fullScreen = flag.Bool("tui", false, "use the full-screen terminal UI instead of printing lines")
+		logFile    = flag.String("log", "", "file the terminal UI writes logs to, they are dropped when empty")
 	)
 	flag.Parse()
 
@@ -85,19 +263,37 @@ func main() {
 	if *listenAt == "" {
 		*listenAt = fmt.Sprintf("127.0.0.1:%d", rand.Int31n(50000)+10000)
 	}
-	rem := remote.New(*listenAt, remote.NewConfig())
+	listen, err := network.ListenAddress(*listenAt, "127.0.0.1")
+	if err == nil {
+		*advertise, err = network.AdvertiseAddress(listen, *advertise)
+	}
+	if err != nil {
+		slog.Error("invalid address", "err", err)
+		os.Exit(2)
+	}
+	rem := network.NewRemote(listen, *advertise)
 	e, err := actor.NewEngine(actor.NewEngineConfig().WithRemote(rem))
 	if err != nil {
 		slog.Error("failed to create engine", "err", err)
 		os.Exit(1)
 	}
 
+	out := terminal(newConsole(os.Stdin, os.Stdout))
+	if *fullScreen {
+		out, err = startTUI(*logFile)
+		if err != nil {
+			slog.Error("failed to start the terminal UI", "err", err)
+			os.Exit(1)
+		}
+	}
+	defer out.Close()
+
 	var (
 		// the process ID of the server
 		serverPID = actor.NewPID(*connectTo, "server/primary")
 		// Spawn our client receiver
-		clientPID = e.Spawn(newClient(*username, serverPID), "client", actor.WithID(*username))
-		scanner   = bufio.NewScanner(os.Stdin)
+		renames   = make(chan struct{}, 1)
+		clientPID = e.Spawn(newClient(*username, *room, *token, *silence, renames, serverPID, out), "client", actor.WithID(*username))
 	)
 
 	// Interrupt handling
@@ -105,24 +301,56 @@ func main() {
 	go func() {
 		<-exitChan
 		cleanup(serverPID, clientPID, e)
+		out.Close()
 		os.Exit(1)
 	}()
 
-	fmt.Println("Type 'quit' and press return to exit.")
-	for scanner.Scan() {
-		msg := &types.Message{
-			Msg:      scanner.Text(),
-			Username: *username,
+	out.Printf("Type 'quit' and press return to exit.\n")
+	out.Printf("Type /help to list the commands.\n")
+	for {
+		line, ok := out.ReadLine()
+		if !ok {
+			break
 		}
-		// We use SendWithSender here so the server knows who
-		// is sending the message.
-		if msg.Msg == "quit" {
+		if line == "quit" {
 			cleanup(serverPID, clientPID, e)
 			break
 		}
+		var msg any
+		select {
+		case <-renames:
+			msg = &types.Connect{Username: strings.TrimSpace(line), Room: *room}
+		default:
+			msg = parseInput(line, out)
+		}
+		if msg == nil {
+			continue
+		}
+		// We use SendWithSender here so the server knows who
+		// is sending the message.
 		e.SendWithSender(serverPID, msg, clientPID)
 	}
-	if err := scanner.Err(); err != nil {
-		slog.Error("failed to read message from stdin", "err", err)
+}
+
+/*
+ * Takes over the terminal with the full-screen UI. Logs would get in its
+ * way, they are written to the given file or dropped.
+ */
+func startTUI(logFile string) (terminal, error) {
+	logs := io.Discard
+	if logFile != "" {
+		f, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		logs = f
 	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(logs, nil)))
+
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return nil, err
+	}
+
+	return newTUI(screen)
 }
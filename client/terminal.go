@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+	"werewolves-go/types"
+)
+
+/*
+ * terminal is where the client shows what happens and reads what the user
+ * types. Besides lines of text, it is told about the room, role, phase and
+ * players so that it may keep them in sight.
+ */
+type terminal interface {
+	Printf(format string, args ...any)
+	Joined(room string)
+	Role(role string)
+	Phase(phase string, deadline time.Time)
+	Players(players []*types.Player)
+	// Returns the next line typed by the user, false once there is none.
+	ReadLine() (string, bool)
+	Close()
+}
+
+/*
+ * console prints every message on its own line and reads raw lines, which
+ * works in any terminal and with redirected input.
+ */
+type console struct {
+	out     io.Writer
+	scanner *bufio.Scanner
+}
+
+func newConsole(in io.Reader, out io.Writer) *console {
+	return &console{out: out, scanner: bufio.NewScanner(in)}
+}
+
+func (c *console) Printf(format string, args ...any) {
+	fmt.Fprintf(c.out, format, args...)
+}
+
+// The console prints the messages telling about these, it has nothing to keep.
+func (c *console) Joined(string)           {}
+func (c *console) Role(string)             {}
+func (c *console) Phase(string, time.Time) {}
+func (c *console) Players([]*types.Player) {}
+func (c *console) Close()                  {}
+
+func (c *console) ReadLine() (string, bool) {
+	if !c.scanner.Scan() {
+		if err := c.scanner.Err(); err != nil {
+			slog.Error("failed to read message from stdin", "err", err)
+		}
+		return "", false
+	}
+
+	return c.scanner.Text(), true
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+	"werewolves-go/types"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+)
+
+// Number of lines kept in the chat pane.
+const scrollback_limit int = 1000
+
+// Width of the player sidebar, it is hidden on narrow terminals.
+const sidebar_width int = 24
+
+var (
+	headerStyle  = tcell.StyleDefault.Reverse(true)
+	roleStyle    = headerStyle.Foreground(tcell.ColorYellow).Bold(true)
+	errorStyle   = tcell.StyleDefault.Foreground(tcell.ColorRed)
+	channelStyle = tcell.StyleDefault.Foreground(tcell.ColorFuchsia)
+	aliveStyle   = tcell.StyleDefault.Foreground(tcell.ColorGreen)
+	deadStyle    = tcell.StyleDefault.Foreground(tcell.ColorGray).StrikeThrough(true)
+	awayStyle    = tcell.StyleDefault.Foreground(tcell.ColorGray)
+)
+
+/*
+ * tui is a full-screen terminal: the room, role, phase and time left sit
+ * at the top, messages scroll in a pane above the line being typed and
+ * the players are listed on the side. Incoming messages never get in the
+ * way of typing.
+ */
+type tui struct {
+	mu        sync.Mutex
+	screen    tcell.Screen
+	lines     []string
+	scroll    int
+	input     []rune
+	room      string
+	role      string
+	phase     string
+	deadline  time.Time
+	players   []*types.Player
+	submitted chan string
+	done      chan struct{}
+	closing   sync.Once
+}
+
+/*
+ * Takes over the given screen until Close is called. The screen is
+ * redrawn every second for the countdown to run.
+ */
+func newTUI(screen tcell.Screen) (*tui, error) {
+	if err := screen.Init(); err != nil {
+		return nil, err
+	}
+
+	t := &tui{
+		screen:    screen,
+		submitted: make(chan string),
+		done:      make(chan struct{}),
+	}
+	go t.poll()
+	go t.tick()
+	t.redraw()
+
+	return t, nil
+}
+
+func (t *tui) Printf(format string, args ...any) {
+	t.update(func() {
+		text := strings.TrimSuffix(fmt.Sprintf(format, args...), "\n")
+		t.lines = append(t.lines, strings.Split(text, "\n")...)
+		if len(t.lines) > scrollback_limit {
+			t.lines = t.lines[len(t.lines)-scrollback_limit:]
+		}
+	})
+}
+
+func (t *tui) Joined(room string) {
+	t.update(func() { t.room = room })
+}
+
+func (t *tui) Role(role string) {
+	t.update(func() { t.role = role })
+}
+
+func (t *tui) Phase(phase string, deadline time.Time) {
+	t.update(func() { t.phase, t.deadline = phase, deadline })
+}
+
+func (t *tui) Players(players []*types.Player) {
+	t.update(func() { t.players = players })
+}
+
+func (t *tui) ReadLine() (string, bool) {
+	line, ok := <-t.submitted
+	return line, ok
+}
+
+// Gives the terminal back to the shell.
+func (t *tui) Close() {
+	t.closing.Do(func() {
+		close(t.done)
+		t.screen.Fini()
+	})
+}
+
+// Applies a change to what is shown and draws the screen again.
+func (t *tui) update(change func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	change()
+	t.draw()
+}
+
+func (t *tui) redraw() {
+	t.update(func() {})
+}
+
+func (t *tui) tick() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+			t.redraw()
+		}
+	}
+}
+
+/*
+ * Handles the keys pressed by the user until the screen is closed. Return
+ * submits the line typed, ctrl-c quits and the page keys scroll through
+ * the messages.
+ */
+func (t *tui) poll() {
+	defer close(t.submitted)
+
+	for {
+		var line string
+		var submit bool
+		switch ev := t.screen.PollEvent().(type) {
+		case nil:
+			return
+		case *tcell.EventResize:
+			t.screen.Sync()
+			t.redraw()
+		case *tcell.EventKey:
+			t.update(func() { line, submit = t.key(ev) })
+		}
+
+		if submit {
+			select {
+			case t.submitted <- line:
+			case <-t.done:
+				return
+			}
+		}
+	}
+}
+
+// Edits the line typed with the given key, returns it once submitted.
+func (t *tui) key(ev *tcell.EventKey) (string, bool) {
+	_, height := t.screen.Size()
+	page := max(height/2, 1)
+
+	switch ev.Key() {
+	case tcell.KeyCtrlC:
+		return "quit", true
+	case tcell.KeyEnter:
+		line := string(t.input)
+		t.input, t.scroll = nil, 0
+		return line, true
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if len(t.input) > 0 {
+			t.input = t.input[:len(t.input)-1]
+		}
+	case tcell.KeyCtrlU:
+		t.input = nil
+	case tcell.KeyPgUp:
+		t.scroll += page
+	case tcell.KeyPgDn:
+		t.scroll = max(t.scroll-page, 0)
+	case tcell.KeyRune:
+		t.input = append(t.input, ev.Rune())
+	}
+
+	return "", false
+}
+
+/*
+ * Draws the whole screen: the header on the first line, the messages and
+ * the players in between and the line being typed at the bottom.
+ */
+func (t *tui) draw() {
+	t.screen.Clear()
+	width, height := t.screen.Size()
+	if height < 4 {
+		t.screen.Show()
+		return
+	}
+
+	paneWidth := width
+	if width >= 3*sidebar_width {
+		paneWidth = width - sidebar_width - 1
+		t.drawPlayers(paneWidth+1, 1, sidebar_width, height-3)
+		for y := 1; y < height-2; y++ {
+			t.screen.SetContent(paneWidth, y, tcell.RuneVLine, nil, tcell.StyleDefault)
+		}
+	}
+
+	t.drawHeader(width)
+	t.drawMessages(paneWidth, height-3)
+	for x := 0; x < width; x++ {
+		t.screen.SetContent(x, height-2, tcell.RuneHLine, nil, tcell.StyleDefault)
+	}
+	t.drawInput(width, height-1)
+	t.screen.Show()
+}
+
+func (t *tui) drawHeader(width int) {
+	for x := 0; x < width; x++ {
+		t.screen.SetContent(x, 0, ' ', nil, headerStyle)
+	}
+
+	x := t.print(1, 0, width, "werewolves", headerStyle)
+	if t.room != "" {
+		x = t.print(x, 0, width, " | room "+t.room, headerStyle)
+	}
+	if t.role != "" {
+		x = t.print(x, 0, width, " | you are a ", headerStyle)
+		x = t.print(x, 0, width, t.role, roleStyle)
+	}
+	if t.phase != "" {
+		left := max(time.Until(t.deadline).Round(time.Second), 0)
+		t.print(x, 0, width, fmt.Sprintf(" | %v %d:%02d", t.phase, int(left.Minutes()), int(left.Seconds())%60), headerStyle)
+	}
+}
+
+/*
+ * Draws the latest messages that fit in the pane, wrapped to its width,
+ * or older ones when the user scrolled up.
+ */
+func (t *tui) drawMessages(width int, height int) {
+	var rows []string
+	for _, line := range t.lines {
+		rows = append(rows, wrap(line, width)...)
+	}
+
+	t.scroll = min(t.scroll, max(len(rows)-height, 0))
+	end := len(rows) - t.scroll
+	start := max(end-height, 0)
+	for y, row := range rows[start:end] {
+		style := tcell.StyleDefault
+		if strings.HasPrefix(row, "error: ") {
+			style = errorStyle
+		} else if strings.HasPrefix(row, "[") {
+			style = channelStyle
+		}
+		t.print(0, 1+y, width, row, style)
+	}
+}
+
+// Lists the players, whether they are alive and whether they are still there.
+func (t *tui) drawPlayers(x int, y int, width int, height int) {
+	t.print(x+1, y, x+width, "Players", tcell.StyleDefault.Bold(true))
+	for i, player := range t.players {
+		if i+1 >= height {
+			break
+		}
+
+		marker, style := "+ ", aliveStyle
+		if !player.Alive {
+			marker, style = "x ", deadStyle
+		}
+		end := t.print(x+1, y+1+i, x+width, marker, style)
+		end = t.print(end, y+1+i, x+width, player.Username, style)
+		if player.Presence != "" && player.Presence != "online" {
+			t.print(end, y+1+i, x+width, " ("+player.Presence+")", awayStyle)
+		}
+	}
+}
+
+// Draws the line being typed, scrolled so that its end stays in sight.
+func (t *tui) drawInput(width int, y int) {
+	input := string(t.input)
+	for runewidth.StringWidth(input)+3 > width && input != "" {
+		_, size := utf8.DecodeRuneInString(input)
+		input = input[size:]
+	}
+
+	x := t.print(0, y, width, "> ", tcell.StyleDefault.Bold(true))
+	x = t.print(x, y, width, input, tcell.StyleDefault)
+	t.screen.ShowCursor(x, y)
+}
+
+// Prints text from x up to the column end and returns where it stopped.
+func (t *tui) print(x int, y int, end int, text string, style tcell.Style) int {
+	for _, r := range text {
+		w := runewidth.RuneWidth(r)
+		if x+w > end {
+			break
+		}
+		t.screen.SetContent(x, y, r, nil, style)
+		x += w
+	}
+
+	return x
+}
+
+// Splits a line into rows no wider than width, breaking between words when it can.
+func wrap(line string, width int) []string {
+	if width <= 0 {
+		return nil
+	}
+
+	var rows []string
+	for runewidth.StringWidth(line) > width {
+		cut := len(runewidth.Truncate(line, width, ""))
+		if space := strings.LastIndex(line[:cut], " "); space > 0 {
+			cut = space
+		}
+		rows = append(rows, line[:cut])
+		line = strings.TrimPrefix(line[cut:], " ")
+	}
+
+	return append(rows, line)
+}
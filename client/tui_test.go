@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"werewolves-go/types"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Returns the text shown on every row of the screen.
+func rows(screen tcell.SimulationScreen) []string {
+	cells, width, height := screen.GetContents()
+
+	var rows []string
+	for y := 0; y < height; y++ {
+		var row strings.Builder
+		for _, cell := range cells[y*width : (y+1)*width] {
+			row.Write(cell.Bytes)
+		}
+		rows = append(rows, row.String())
+	}
+
+	return rows
+}
+
+func TestTUI(t *testing.T) {
+	screen := tcell.NewSimulationScreen("UTF-8")
+	ui, err := newTUI(screen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ui.Close()
+	screen.SetSize(80, 8)
+
+	ui.Joined("main")
+	ui.Role("seer")
+	ui.Phase("townspersonvote", time.Now().Add(90*time.Second))
+	ui.Players([]*types.Player{
+		{Username: "a", Alive: true, Presence: "online"},
+		{Username: "b", Alive: false, Presence: "online"},
+		{Username: "c", Alive: true, Presence: "away"},
+	})
+	for i := 0; i < 10; i++ {
+		ui.Printf("line %d\n", i)
+	}
+	ui.Printf("[werewolf] d: " + strings.Repeat("long ", 20) + "\n")
+
+	shown := rows(screen)
+	if !strings.Contains(shown[0], "room main | you are a seer | townspersonvote 1:") {
+		t.Errorf("header = %q", shown[0])
+	}
+	for y, want := range []string{"Players", "+ a", "x b", "+ c (away)"} {
+		if !strings.Contains(shown[1+y], want) {
+			t.Errorf("row %d = %q, want %q in the sidebar", 1+y, shown[1+y], want)
+		}
+	}
+
+	// The latest messages fill the pane, wrapped to its width.
+	if !strings.HasPrefix(shown[2], "line 9") || !strings.HasPrefix(shown[3], "[werewolf] d: long") || !strings.HasPrefix(shown[4], "long") {
+		t.Errorf("messages = %q", shown[1:5])
+	}
+
+	// Typing stays on its own line until return is pressed.
+	for _, r := range "/vote b" {
+		screen.InjectKey(tcell.KeyRune, r, tcell.ModNone)
+	}
+	screen.InjectKey(tcell.KeyEnter, 0, tcell.ModNone)
+	if line, ok := ui.ReadLine(); !ok || line != "/vote b" {
+		t.Errorf("ReadLine() = %q, %v", line, ok)
+	}
+
+	screen.InjectKey(tcell.KeyCtrlC, 0, tcell.ModNone)
+	if line, _ := ui.ReadLine(); line != "quit" {
+		t.Errorf("ctrl-c read %q, want quit", line)
+	}
+}
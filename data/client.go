@@ -13,3 +13,9 @@ type Client struct {
 func NewClient(name string, role string) *Client {
 	return &Client{Name: name, Role: role, Status: true}
 }
+
+// Clears the role and brings the client back to life for a new game.
+func (client *Client) Reset() {
+	client.Role = ""
+	client.Status = true
+}
@@ -2,6 +2,7 @@ package data
 
 import (
 	"fmt"
+	"maps"
 	"math"
 )
 
@@ -67,6 +68,16 @@ func (voters *Voters) AddVote(user string, sender string) bool {
 	}
 }
 
+// Tells whether sender has already voted.
+func (voters *Voters) HasVoted(sender string) bool {
+	return voters.voted_users[sender]
+}
+
+// Returns the number of votes every user got so far.
+func (voters *Voters) Votes() map[string]int {
+	return maps.Clone(voters.user_vote)
+}
+
 // Clear the votes to reuse voting object.
 func (voter *Voters) ClearVotes() {
 	for user := range voter.user_vote {
@@ -0,0 +1,136 @@
+package game
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+/*
+ * Clock tells the time and schedules callbacks. Games are driven by a
+ * Clock so that tests and simulations can move time forward instantly.
+ */
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a callback scheduled on a Clock.
+type Timer interface {
+	Stop() bool
+}
+
+type realClock struct{}
+
+// Returns the clock backed by the time package.
+func RealClock() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+/*
+ * FakeClock is a Clock whose time only moves when told to. Callbacks run
+ * on the goroutine moving the clock.
+ */
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	when  time.Time
+	f     func()
+}
+
+// Creates a fake clock reading now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{clock: c, when: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+
+	return t
+}
+
+/*
+ * Moves the clock forward by d, running every callback that falls due on
+ * the way in chronological order.
+ */
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+
+	for c.fire(end) {
+	}
+
+	c.mu.Lock()
+	c.now = end
+	c.mu.Unlock()
+}
+
+/*
+ * Jumps straight to the earliest pending callback and runs it. Returns
+ * false when nothing is scheduled.
+ */
+func (c *FakeClock) Next() bool {
+	return c.fire(time.Time{})
+}
+
+/*
+ * Runs the earliest callback due at or before until, or the earliest one at
+ * all when until is zero.
+ */
+func (c *FakeClock) fire(until time.Time) bool {
+	c.mu.Lock()
+	if len(c.timers) == 0 {
+		c.mu.Unlock()
+		return false
+	}
+
+	t := slices.MinFunc(c.timers, func(a, b *fakeTimer) int { return a.when.Compare(b.when) })
+	if !until.IsZero() && t.when.After(until) {
+		c.mu.Unlock()
+		return false
+	}
+
+	c.timers = slices.DeleteFunc(c.timers, func(other *fakeTimer) bool { return other == t })
+	if t.when.After(c.now) {
+		c.now = t.when
+	}
+	c.mu.Unlock()
+
+	t.f()
+	return true
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	n := len(t.clock.timers)
+	t.clock.timers = slices.DeleteFunc(t.clock.timers, func(other *fakeTimer) bool { return other == t })
+
+	return len(t.clock.timers) != n
+}
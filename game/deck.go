@@ -0,0 +1,168 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+ * Deck describes the roles dealt at the start of a game, for instance
+ * "2 werewolf, 1 witch, 1 seer, rest villager". Every listed role is dealt
+ * and the players left over get the rest role.
+ */
+type Deck struct {
+	Cards []Card
+	Rest  string
+}
+
+/*
+ * Card is a role of the deck together with how many players get it.
+ */
+type Card struct {
+	Role  string
+	Count int
+}
+
+// Other names the built-in roles are known by in a deck.
+var roleAliases = map[string]string{
+	"villager":   Townsperson,
+	"villagers":  Townsperson,
+	"werewolves": Werewolf,
+	"witches":    Witch,
+}
+
+/*
+ * Parses a deck specification made of comma separated "<count> <role>"
+ * entries and an optional "rest <role>" entry. The rest are townspersons
+ * when no rest role is given.
+ */
+func ParseDeck(spec string) (Deck, error) {
+	deck := Deck{Rest: Townsperson}
+	seen := make(map[string]bool)
+	rest := false
+
+	for _, entry := range strings.Split(spec, ",") {
+		fields := strings.Fields(entry)
+		if len(fields) != 2 {
+			return Deck{}, fmt.Errorf("Deck entry %q is not of the form \"<count> <role>\".", strings.TrimSpace(entry))
+		}
+
+		role, err := deckRole(fields[1])
+		if err != nil {
+			return Deck{}, err
+		}
+
+		if fields[0] == "rest" {
+			if rest {
+				return Deck{}, errors.New("Deck has more than one rest entry.")
+			}
+			rest = true
+			deck.Rest = role
+			continue
+		}
+
+		count, err := strconv.Atoi(fields[0])
+		if err != nil || count < 1 {
+			return Deck{}, fmt.Errorf("Deck entry %q needs a positive count.", strings.TrimSpace(entry))
+		} else if seen[role] {
+			return Deck{}, fmt.Errorf("Role %v is listed more than once in the deck.", role)
+		}
+		seen[role] = true
+		deck.Cards = append(deck.Cards, Card{Role: role, Count: count})
+	}
+
+	if rest, _ := LookupRole(deck.Rest); deck.count(Werewolves) == 0 && rest.Team() != Werewolves {
+		return Deck{}, fmt.Errorf("Deck %q has no werewolf.", deck)
+	}
+	// A deck works for one more player than it lists unless it works for none.
+	if deck.Check(deck.Size()+1) != nil {
+		return Deck{}, fmt.Errorf("Deck %q leaves no villager whatever the number of players.", deck)
+	}
+
+	return deck, nil
+}
+
+// Resolves a role name of a deck entry, accepting aliases and plurals.
+func deckRole(name string) (string, error) {
+	name = strings.ToLower(name)
+	if alias, ok := roleAliases[name]; ok {
+		return alias, nil
+	}
+	for _, candidate := range []string{name, strings.TrimSuffix(name, "s")} {
+		if _, ok := LookupRole(candidate); ok {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("Unknown role %q, pick one of %v.", name, strings.Join(Roles(), ", "))
+}
+
+// Returns the number of roles listed in the deck.
+func (d Deck) Size() int {
+	size := 0
+	for _, card := range d.Cards {
+		size += card.Count
+	}
+
+	return size
+}
+
+// Returns how many listed roles play for the given team.
+func (d Deck) count(team Team) int {
+	count := 0
+	for _, card := range d.Cards {
+		if role, ok := LookupRole(card.Role); ok && role.Team() == team {
+			count += card.Count
+		}
+	}
+
+	return count
+}
+
+/*
+ * Checks that a game of the given number of players can be dealt from the
+ * deck and is not over before it started.
+ */
+func (d Deck) Check(players int) error {
+	if size := d.Size(); players < size {
+		return fmt.Errorf("The deck %q needs at least %d players but only %d joined.", d, size, players)
+	}
+
+	werewolves := d.count(Werewolves)
+	if rest, ok := LookupRole(d.Rest); ok && rest.Team() == Werewolves {
+		werewolves += players - d.Size()
+	}
+	if werewolves >= players {
+		return fmt.Errorf("The deck %q leaves no villager among %d players.", d, players)
+	}
+
+	return nil
+}
+
+// Returns the roles to deal to the given number of players.
+func (d Deck) deal(players int) []string {
+	var roles []string
+	for _, card := range d.Cards {
+		for i := 0; i < card.Count; i++ {
+			roles = append(roles, card.Role)
+		}
+	}
+	for len(roles) < players {
+		roles = append(roles, d.Rest)
+	}
+
+	return roles
+}
+
+// Formats the deck the way ParseDeck reads it.
+func (d Deck) String() string {
+	entries := make([]string, 0, len(d.Cards)+1)
+	for _, card := range d.Cards {
+		entries = append(entries, fmt.Sprintf("%d %v", card.Count, card.Role))
+	}
+	entries = append(entries, "rest "+d.Rest)
+
+	return strings.Join(entries, ", ")
+}
@@ -0,0 +1,1036 @@
+package game
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+	"werewolves-go/data"
+)
+
+/*
+ * Config holds the tunable parameters of a game. Roles are dealt from Deck
+ * when it is set and from the preset meant for the number of players
+ * otherwise. Offline says what the game does about players who lost their
+ * connection, see WaitForOffline.
+ */
+type Config struct {
+	MinPlayers            int
+	HealPotions           int
+	PoisonPotions         int
+	ConnectionDuration    time.Duration
+	WerewolfDiscussion    time.Duration
+	TownspersonDiscussion time.Duration
+	VotingDuration        time.Duration
+	SeerDuration          time.Duration
+	HunterDuration        time.Duration
+	CupidDuration         time.Duration
+	BodyguardDuration     time.Duration
+	WitchHealDuration     time.Duration
+	RematchDuration       time.Duration
+	Offline               string
+	Deck                  *Deck
+	Presets               []Preset
+	Rand                  *rand.Rand
+	Logger                *slog.Logger
+}
+
+/*
+ * Returns the configuration the server has always been played with.
+ */
+func DefaultConfig() Config {
+	return Config{
+		MinPlayers:            4,
+		HealPotions:           1,
+		PoisonPotions:         1,
+		ConnectionDuration:    60 * time.Second,
+		WerewolfDiscussion:    60 * time.Second,
+		TownspersonDiscussion: 120 * time.Second,
+		VotingDuration:        60 * time.Second,
+		SeerDuration:          30 * time.Second,
+		HunterDuration:        20 * time.Second,
+		CupidDuration:         30 * time.Second,
+		BodyguardDuration:     30 * time.Second,
+		WitchHealDuration:     30 * time.Second,
+		RematchDuration:       60 * time.Second,
+		Offline:               WaitForOffline,
+		Presets:               DefaultPresets(),
+	}
+}
+
+/*
+ * Engine holds the rules of a single werewolf game. It consumes inputs and
+ * returns the events they caused; it never talks to the network itself.
+ * An Engine is not safe for concurrent use.
+ */
+type Engine struct {
+	cfg            Config
+	rand           *rand.Rand
+	logger         *slog.Logger
+	state          State
+	deadline       time.Time
+	now            time.Time
+	round          int
+	players        map[string]*data.Client
+	order          []string
+	werewolfVotes  *data.Voters
+	townVotes      *data.Voters
+	usedHeals      map[string]int
+	protections    []protection
+	guarded        map[string]string
+	lastGuarded    map[string]string
+	usedPoisons    map[string]int
+	poisoned       []string
+	werewolfTarget string
+	inspected      map[string]bool
+	hunters        []string
+	hunter         string
+	resume         func(now time.Time) []Event
+	lovers         map[string]string
+	linked         map[string]bool
+	rematch        map[string]bool
+	offline        map[string]bool
+}
+
+/*
+ * Creates an engine waiting for players. The connection window closes
+ * cfg.ConnectionDuration after now.
+ */
+func NewEngine(cfg Config, now time.Time) *Engine {
+	e := &Engine{
+		cfg:         cfg,
+		rand:        cfg.Rand,
+		logger:      cfg.Logger,
+		state:       Connect,
+		deadline:    now.Add(cfg.ConnectionDuration),
+		now:         now,
+		players:     make(map[string]*data.Client),
+		offline:     make(map[string]bool),
+		usedHeals:   make(map[string]int),
+		usedPoisons: make(map[string]int),
+		lovers:      make(map[string]string),
+		linked:      make(map[string]bool),
+	}
+	if e.rand == nil {
+		e.rand = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+	if e.logger == nil {
+		e.logger = slog.Default()
+	}
+
+	return e
+}
+
+// Returns the current state of the game.
+func (e *Engine) State() State {
+	return e.state
+}
+
+// Returns the time at which the current state ends.
+func (e *Engine) Deadline() time.Time {
+	return e.deadline
+}
+
+// Returns the player registered under the given id, nil if unknown.
+func (e *Engine) Player(id string) *data.Client {
+	return e.players[id]
+}
+
+// Returns the ids of the players in the order they joined.
+func (e *Engine) Players() []string {
+	return slices.Clone(e.order)
+}
+
+/*
+ * Returns how many votes every player got in the vote going on, or nil
+ * when nobody is voting.
+ */
+func (e *Engine) Votes() map[string]int {
+	switch {
+	case e.state == WerewolfVote && e.werewolfVotes != nil:
+		return e.werewolfVotes.Votes()
+	case e.state == TownVote && e.townVotes != nil:
+		return e.townVotes.Votes()
+	}
+
+	return nil
+}
+
+/*
+ * Handle applies a single input to the game and returns the resulting events
+ * in the order they must be delivered.
+ */
+func (e *Engine) Handle(in Input) []Event {
+	var out []Event
+	switch in := in.(type) {
+	case Join:
+		out = e.join(in)
+	case Leave:
+		out = e.leave(in)
+	case Say:
+		out = e.say(in)
+	case Vote:
+		if e.state == TownVote {
+			out = e.vote(in, e.townVotes)
+		} else {
+			out = e.act(in.Player, in, "vote")
+		}
+	case Heal:
+		out = e.act(in.Player, in, "heal")
+	case Poison:
+		out = e.act(in.Player, in, "poison")
+	case Inspect:
+		out = e.act(in.Player, in, "inspect")
+	case Protect:
+		out = e.act(in.Player, in, "protect")
+	case Shoot:
+		out = e.shoot(in)
+	case Link:
+		out = e.act(in.Player, in, "link lovers")
+	case Rematch:
+		out = e.voteRematch(in)
+	case Offline:
+		if _, ok := e.players[in.Player]; ok {
+			e.offline[in.Player] = true
+		}
+	case Online:
+		delete(e.offline, in.Player)
+	case Tick:
+		out = e.tick(in.Now)
+	}
+	e.stopWaiting()
+
+	return out
+}
+
+func (e *Engine) join(in Join) []Event {
+	if e.state != Connect && e.state != End {
+		return []Event{Rejected{Player: in.Player, Text: "Game has already started."}}
+	}
+	if _, ok := e.players[in.Player]; ok {
+		e.logger.Warn("player already joined", "player", in.Player)
+		return nil
+	}
+	for _, player := range e.players {
+		if strings.EqualFold(player.Name, in.Name) {
+			return []Event{Rejected{Player: in.Player, Text: fmt.Sprintf("%v is already taken in this game.", in.Name)}}
+		}
+	}
+
+	e.players[in.Player] = data.NewClient(in.Name, "")
+	e.order = append(e.order, in.Player)
+
+	out := []Event{Announcement{Text: fmt.Sprintf("%v connected", in.Name)}}
+	if len(e.players) == e.cfg.MinPlayers {
+		out = append(out, Announcement{Text: "Minimum players reached. Ready to begin once the connection time is over!!"})
+	}
+
+	return out
+}
+
+func (e *Engine) leave(in Leave) []Event {
+	if _, ok := e.players[in.Player]; !ok {
+		return nil
+	}
+
+	delete(e.players, in.Player)
+	delete(e.rematch, in.Player)
+	delete(e.offline, in.Player)
+	e.order = slices.DeleteFunc(e.order, func(id string) bool { return id == in.Player })
+
+	return nil
+}
+
+/*
+ * Relays free text as chat. Players may talk to everyone during the day and
+ * once the game is over, at night only awake players talk to each other.
+ */
+func (e *Engine) say(in Say) []Event {
+	player, ok := e.players[in.Player]
+	if !ok {
+		return nil
+	}
+
+	// Once the game is over everyone may talk again.
+	if e.state == End {
+		return []Event{Chat{From: in.Player, Name: player.Name, To: e.othersThan(in.Player, e.order), Channel: TownChannel, Text: in.Text}}
+	}
+
+	// Check for whether the person is dead or alive
+	if !player.Status {
+		return []Event{Rejected{Player: in.Player, Text: "Bruh, you cant message when you are dead!"}}
+	}
+
+	// Do not accept messages if the game is closed
+	if e.state == Closed {
+		return []Event{Rejected{Player: in.Player, Text: "The game has ended. Thank you for playing!"}}
+	}
+
+	// Only allow messages to be processed if they are in the allowed list
+	allowed := e.allowedPlayers()
+	if !slices.Contains(allowed, in.Player) {
+		return []Event{Rejected{Player: in.Player,
+			Text: fmt.Sprintf("You are not allowed to send messages in %v", e.state)}}
+	}
+
+	// At night only the players sharing the sender's role can hear them.
+	channel := TownChannel
+	if isNightState(e.state) {
+		channel = player.Role
+	}
+
+	return []Event{Chat{From: in.Player, Name: player.Name, To: e.othersThan(in.Player, allowed), Channel: channel, Text: in.Text}}
+}
+
+/*
+ * Records that a player wants to play again. Once a majority of the
+ * players agrees the game is reset on the next tick.
+ */
+func (e *Engine) voteRematch(in Rematch) []Event {
+	player, ok := e.players[in.Player]
+	if !ok {
+		return nil
+	}
+	if e.state != End {
+		return []Event{Rejected{Player: in.Player, Text: "You can only ask for a rematch once the game is over."}}
+	}
+	if e.rematch[in.Player] {
+		return []Event{Rejected{Player: in.Player, Text: "You already asked for a rematch."}}
+	}
+
+	e.rematch[in.Player] = true
+	out := []Event{Announcement{Text: fmt.Sprintf("%v wants a rematch (%d/%d)", player.Name, len(e.rematch), len(e.players))}}
+
+	if e.rematchAgreed() {
+		// Let the current state expire right away.
+		e.deadline = e.now
+	}
+
+	return out
+}
+
+// A rematch needs more than half of the players.
+func (e *Engine) rematchAgreed() bool {
+	return len(e.rematch)*2 > len(e.players)
+}
+
+/*
+ * Hands an action input to the role of the player, who must be alive and
+ * awake in the current state. Actions no role takes are rejected.
+ */
+func (e *Engine) act(id string, in Input, action string) []Event {
+	player, ok := e.players[id]
+	if !ok {
+		return nil
+	}
+
+	if role := e.roleOf(id); player.Status && role != nil && role.Wakes(e.state) {
+		if out, ok := role.Act(e, id, in); ok {
+			return out
+		}
+	}
+
+	return []Event{Rejected{Player: id,
+		Text: fmt.Sprintf("You are not allowed to %v in %v", action, e.state)}}
+}
+
+// Casts a vote of a living player in the given vote.
+func (e *Engine) vote(in Vote, voters *data.Voters) []Event {
+	player, ok := e.players[in.Player]
+	if !ok || !player.Status {
+		return nil
+	}
+
+	if !slices.Contains(e.aliveNames(), in.Target) {
+		return []Event{Rejected{Player: in.Player, Text: "Please select the elements from the list only.."}}
+	}
+
+	e.logger.Info(fmt.Sprintf("%v has chosen to kill %v", player.Name, in.Target))
+	voters.AddVote(in.Target, player.Name)
+
+	return nil
+}
+
+func (e *Engine) heal(in Heal) []Event {
+	player := e.players[in.Player]
+
+	if in.Target == "" {
+		return []Event{Announcement{Text: "Witch has chosen to pass."}}
+	} else if in.Target != e.werewolfTarget {
+		return []Event{Rejected{Player: in.Player, Text: "Please select the elements from the list only.."}}
+	} else if e.usedHeals[in.Player] >= e.cfg.HealPotions {
+		return []Event{Rejected{Player: in.Player, Text: "No healing potions left!"}}
+	}
+
+	e.logger.Info(fmt.Sprintf("%v has chosen to heal %v", player.Name, in.Target))
+	e.protections = append(e.protections, protection{target: in.Target, news: "The witch saved a person from being killed"})
+	e.usedHeals[in.Player] += 1
+
+	return nil
+}
+
+/*
+ * Poisons any living player. The victim dies with the werewolves' one when
+ * the night is over.
+ */
+func (e *Engine) poison(in Poison) []Event {
+	player := e.players[in.Player]
+
+	if e.usedPoisons[in.Player] >= e.cfg.PoisonPotions {
+		return []Event{Rejected{Player: in.Player, Text: "No poison potions left!"}}
+	} else if target := e.alivePlayerByName(in.Target); target == "" || target == in.Player {
+		return []Event{Rejected{Player: in.Player, Text: "Please select the elements from the list only.."}}
+	}
+
+	e.logger.Info(fmt.Sprintf("%v has chosen to poison %v", player.Name, in.Target))
+	e.poisoned = append(e.poisoned, in.Target)
+	e.usedPoisons[in.Player] += 1
+
+	return []Event{Reply{Player: in.Player, Text: fmt.Sprintf("%v will not see the morning.", in.Target)}}
+}
+
+/*
+ * Takes the target down together with the dying hunter and ends the
+ * hunter's window right away.
+ */
+func (e *Engine) shoot(in Shoot) []Event {
+	hunter, ok := e.players[in.Player]
+	if !ok || e.state != HunterShot || e.hunter == "" || in.Player != e.hunter {
+		return []Event{Rejected{Player: in.Player,
+			Text: fmt.Sprintf("You are not allowed to shoot in %v", e.state)}}
+	}
+
+	target := e.alivePlayerByName(in.Target)
+	if target == "" {
+		return []Event{Rejected{Player: in.Player, Text: "Please select the elements from the list only.."}}
+	}
+
+	e.logger.Info(fmt.Sprintf("%v has chosen to shoot %v", hunter.Name, in.Target))
+	e.hunter = ""
+	// Let the current state expire right away.
+	e.deadline = e.now
+
+	out := []Event{Announcement{Text: fmt.Sprintf("The hunter %v took %v down with them", hunter.Name, in.Target)}}
+	return append(out, e.kill(in.Target)...)
+}
+
+/*
+ * Makes two living players fall in love. Every cupid only shoots once and
+ * the lovers are told about each other. Nobody loves two players.
+ */
+func (e *Engine) link(in Link) []Event {
+	player := e.players[in.Player]
+	if e.linked[in.Player] {
+		return []Event{Rejected{Player: in.Player,
+			Text: fmt.Sprintf("You are not allowed to link lovers in %v", e.state)}}
+	}
+
+	first, second := e.alivePlayerByName(in.First), e.alivePlayerByName(in.Second)
+	if first == "" || second == "" || first == second {
+		return []Event{Rejected{Player: in.Player, Text: "Please select the elements from the list only.."}}
+	} else if e.lovers[first] != "" || e.lovers[second] != "" {
+		return []Event{Rejected{Player: in.Player, Text: "Cupid's arrow already hit one of them."}}
+	}
+
+	e.logger.Info(fmt.Sprintf("%v has chosen to link %v and %v", player.Name, in.First, in.Second))
+	e.lovers[first], e.lovers[second] = second, first
+	e.linked[in.Player] = true
+	// Let the current state expire right away once every cupid shot.
+	if !slices.ContainsFunc(e.wakingIn(CupidLink), func(id string) bool { return !e.linked[id] }) {
+		e.deadline = e.now
+	}
+
+	return []Event{
+		Reply{Player: in.Player, Text: fmt.Sprintf("%v and %v are now in love.", in.First, in.Second)},
+		PrivateMessage{Player: first, Text: fmt.Sprintf("Cupid's arrow hit you. You are in love with %v.", in.Second)},
+		PrivateMessage{Player: second, Text: fmt.Sprintf("Cupid's arrow hit you. You are in love with %v.", in.First)},
+	}
+}
+
+/*
+ * Shields the target from the werewolves tonight. A bodyguard cannot
+ * protect the same player two nights in a row.
+ */
+func (e *Engine) protect(in Protect) []Event {
+	player := e.players[in.Player]
+
+	if _, ok := e.guarded[in.Player]; ok {
+		return []Event{Rejected{Player: in.Player, Text: "You already chose who to protect tonight."}}
+	}
+
+	target := e.alivePlayerByName(in.Target)
+	if target == "" {
+		return []Event{Rejected{Player: in.Player, Text: "Please select the elements from the list only.."}}
+	} else if e.lastGuarded[in.Player] == in.Target {
+		return []Event{Rejected{Player: in.Player,
+			Text: fmt.Sprintf("You cannot protect %v two nights in a row.", in.Target)}}
+	}
+
+	e.logger.Info(fmt.Sprintf("%v has chosen to protect %v", player.Name, in.Target))
+	e.guarded[in.Player] = in.Target
+	e.protections = append(e.protections, protection{target: in.Target, news: "The bodyguard protected a person from being killed"})
+
+	return []Event{Reply{Player: in.Player, Text: fmt.Sprintf("You will watch over %v tonight.", in.Target)}}
+}
+
+/*
+ * Tells the seer whether the chosen player is a werewolf. The seer gets a
+ * single vision per night.
+ */
+func (e *Engine) inspect(in Inspect) []Event {
+	player := e.players[in.Player]
+
+	if e.inspected[in.Player] {
+		return []Event{Rejected{Player: in.Player, Text: "You already had your vision tonight."}}
+	}
+
+	target := e.alivePlayerByName(in.Target)
+	if target == "" || target == in.Player {
+		return []Event{Rejected{Player: in.Player, Text: "Please select the elements from the list only.."}}
+	}
+
+	e.logger.Info(fmt.Sprintf("%v has chosen to inspect %v", player.Name, in.Target))
+	e.inspected[in.Player] = true
+	if e.roleOf(target).AppearsAs() == Werewolves {
+		return []Event{Reply{Player: in.Player, Text: fmt.Sprintf("%v is a werewolf!", in.Target)}}
+	}
+
+	return []Event{Reply{Player: in.Player, Text: fmt.Sprintf("%v is not a werewolf.", in.Target)}}
+}
+
+/*
+ * Moves the game past every deadline that has elapsed at now.
+ */
+func (e *Engine) tick(now time.Time) []Event {
+	var out []Event
+	e.now = now
+	for e.state != Closed && !now.Before(e.deadline) {
+		out = append(out, e.advance(now)...)
+	}
+
+	return out
+}
+
+/*
+ * Leaves the current state and enters the following one.
+ */
+func (e *Engine) advance(now time.Time) []Event {
+	switch e.state {
+	case Connect:
+		if len(e.players) < e.cfg.MinPlayers {
+			e.deadline = now.Add(e.cfg.ConnectionDuration)
+			return []Event{Announcement{Text: "Minimum player not reached. Extending time...."}}
+		}
+		deck, err := e.deck()
+		if err == nil {
+			err = deck.Check(len(e.players))
+		}
+		if err != nil {
+			e.deadline = now.Add(e.cfg.ConnectionDuration)
+			return []Event{Announcement{Text: err.Error() + " Extending time...."}}
+		}
+		out := e.assignRoles(deck)
+		return append(out, e.enterStart(now)...)
+	case CupidLink:
+		return e.enterWerewolfDiscuss(now)
+	case WerewolfDiscuss:
+		return e.enterWerewolfVote(now)
+	case WerewolfVote:
+		e.werewolfTarget = e.werewolfVotes.GetMaxVotedUser()
+		return e.enterSeerInspect(now)
+	case SeerInspect:
+		return e.enterBodyguardProtect(now)
+	case BodyguardProtect:
+		return e.enterWitchHeal(now)
+	case WitchHeal:
+		return e.resolveNight(now)
+	case TownDiscussion:
+		return e.enterTownVote(now)
+	case TownVote:
+		return e.resolveTownVote(now)
+	case HunterShot:
+		var out []Event
+		if e.hunter != "" {
+			out = append(out, Announcement{Text: "The hunter did not shoot"})
+			e.hunter = ""
+		}
+		next := e.resume
+		e.resume = nil
+		return append(out, e.afterDeaths(now, next)...)
+	case End:
+		if e.rematchAgreed() {
+			return e.reset(now)
+		}
+		e.state = Closed
+		return []Event{
+			PhaseChanged{State: Closed, Deadline: e.deadline},
+			Announcement{Text: "Nobody wanted a rematch. Thank you for playing!"},
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Starts over with every connected player. Roles, potions and votes of the
+ * previous game are forgotten and newcomers may join until the connection
+ * time is over.
+ */
+func (e *Engine) reset(now time.Time) []Event {
+	for _, player := range e.players {
+		player.Reset()
+	}
+
+	e.round = 0
+	e.usedHeals = make(map[string]int)
+	e.protections = nil
+	e.guarded = nil
+	e.lastGuarded = nil
+	e.usedPoisons = make(map[string]int)
+	e.poisoned = nil
+	e.hunters = nil
+	e.hunter = ""
+	e.resume = nil
+	e.lovers = make(map[string]string)
+	e.linked = make(map[string]bool)
+	e.werewolfTarget = ""
+	e.werewolfVotes = nil
+	e.townVotes = nil
+	e.rematch = nil
+
+	return []Event{
+		e.enter(Connect, now, e.cfg.ConnectionDuration),
+		Announcement{Text: fmt.Sprintf("Rematch! A new game begins in %v, new players can still join.", e.cfg.ConnectionDuration)},
+	}
+}
+
+func (e *Engine) enter(state State, now time.Time, d time.Duration) Event {
+	e.state = state
+	e.deadline = now.Add(d)
+	return PhaseChanged{State: state, Deadline: e.deadline}
+}
+
+func (e *Engine) enterStart(now time.Time) []Event {
+	out := []Event{
+		e.enter(Start, now, 0),
+		Announcement{Text: "Night falls and the town sleeps.  Everyone close your eyes"},
+	}
+
+	return append(out, e.enterCupidLink(now)...)
+}
+
+/*
+ * On the first night Cupid picks two players who fall in love.
+ */
+func (e *Engine) enterCupidLink(now time.Time) []Event {
+	cupids := e.wakingIn(CupidLink)
+	if len(cupids) == 0 {
+		return e.enterWerewolfDiscuss(now)
+	}
+
+	out := []Event{
+		e.enter(CupidLink, now, e.cfg.CupidDuration),
+		Announcement{Text: "Cupid, now its time to wake up and pick two lovers"},
+	}
+	for _, id := range cupids {
+		out = append(out, PrivateMessage{Player: id,
+			Text: "Choose two players to fall in love with /link <first> <second>: " + strings.Join(e.aliveNames(), ",")})
+	}
+
+	return out
+}
+
+func (e *Engine) enterWerewolfDiscuss(now time.Time) []Event {
+	e.round += 1
+	out := []Event{
+		e.enter(WerewolfDiscuss, now, e.cfg.WerewolfDiscussion),
+		Announcement{Text: fmt.Sprintf("========== Round: %d ==========", e.round)},
+		Announcement{Text: "Werewolves, open your eyes."},
+	}
+
+	// Go to werewolf vote if there are less than 2 werewolves to discuss.
+	if len(e.wakingIn(WerewolfDiscuss)) < 2 {
+		e.logger.Info(fmt.Sprintf("Not enough werewolves alive for %v state", e.state))
+		return append(out, e.enterWerewolfVote(now)...)
+	}
+
+	return append(out, Announcement{Text: fmt.Sprintf("You have %v time to discuss", e.cfg.WerewolfDiscussion)})
+}
+
+func (e *Engine) enterWerewolfVote(now time.Time) []Event {
+	out := []Event{e.enter(WerewolfVote, now, e.cfg.VotingDuration)}
+	names := e.aliveNames()
+
+	for _, id := range e.wakingIn(WerewolfVote) {
+		out = append(out, PrivateMessage{Player: id,
+			Text: "Choose the player to kill with /vote <name>: " + strings.Join(names, ",")})
+	}
+
+	e.werewolfVotes = data.NewVoters(names)
+
+	return append(out,
+		Announcement{Text: "Werewolves, now its time to vote"},
+		Announcement{Text: fmt.Sprintf("You have %v time to vote", e.cfg.VotingDuration)})
+}
+
+/*
+ * Wakes the seer up. The vision lasts its whole time even when the seer
+ * is dead, so that nobody learns the role of the dead from a short night.
+ */
+func (e *Engine) enterSeerInspect(now time.Time) []Event {
+	if !e.dealt(SeerInspect) {
+		return e.enterBodyguardProtect(now)
+	}
+
+	e.inspected = make(map[string]bool)
+	out := []Event{
+		e.enter(SeerInspect, now, e.cfg.SeerDuration),
+		Announcement{Text: "Seer, now its time to wake up and have a vision"},
+	}
+
+	for _, id := range e.wakingIn(SeerInspect) {
+		names := slices.DeleteFunc(e.aliveNames(), func(name string) bool { return name == e.players[id].Name })
+		out = append(out, PrivateMessage{Player: id,
+			Text: "Choose the player to inspect with /inspect <name>: " + strings.Join(names, ",")})
+	}
+
+	return out
+}
+
+/*
+ * Wakes the bodyguard up, for the whole time of the phase even when the
+ * bodyguard is dead, like the seer.
+ */
+func (e *Engine) enterBodyguardProtect(now time.Time) []Event {
+	e.guarded = make(map[string]string)
+	if !e.dealt(BodyguardProtect) {
+		return e.enterWitchHeal(now)
+	}
+
+	out := []Event{
+		e.enter(BodyguardProtect, now, e.cfg.BodyguardDuration),
+		Announcement{Text: "Bodyguard, now its time to wake up and protect someone"},
+	}
+
+	for _, id := range e.wakingIn(BodyguardProtect) {
+		last := e.lastGuarded[id]
+		names := slices.DeleteFunc(e.aliveNames(), func(name string) bool { return name == last })
+		out = append(out, PrivateMessage{Player: id,
+			Text: "Choose the player to protect with /protect <name>: " + strings.Join(names, ",")})
+	}
+
+	return out
+}
+
+/*
+ * Wakes the witch up. Like the seer's, the phase lasts its whole time even
+ * when the witch is dead or has nothing left to use, and what the witch
+ * can still do is only told to the witch.
+ */
+func (e *Engine) enterWitchHeal(now time.Time) []Event {
+	if !e.dealt(WitchHeal) {
+		return e.resolveNight(now)
+	}
+
+	out := []Event{
+		e.enter(WitchHeal, now, e.cfg.WitchHealDuration),
+		Announcement{Text: "Witch, now its time to wake up"},
+	}
+
+	for _, id := range e.wakingIn(WitchHeal) {
+		canHeal := e.usedHeals[id] < e.cfg.HealPotions && len(e.werewolfTarget) > 0
+		canPoison := e.usedPoisons[id] < e.cfg.PoisonPotions
+		if e.usedHeals[id] >= e.cfg.HealPotions {
+			out = append(out, PrivateMessage{Player: id, Text: "You have used up all healing potions"})
+		}
+		if canHeal {
+			out = append(out,
+				PrivateMessage{Player: id, Text: fmt.Sprintf("The werewolves chose to kill %v", e.werewolfTarget)},
+				PrivateMessage{Player: id, Text: fmt.Sprintf("Type /heal %v to save them or /pass to skip", e.werewolfTarget)})
+		}
+		if canPoison {
+			names := slices.DeleteFunc(e.aliveNames(), func(name string) bool { return name == e.players[id].Name })
+			out = append(out, PrivateMessage{Player: id,
+				Text: "Type /poison <name> to poison one of: " + strings.Join(names, ",")})
+		}
+	}
+
+	return out
+}
+
+/*
+ * Announces what happened during the night. The werewolves' victim survives
+ * if anyone protected them, and every protector that did is announced.
+ * Dying hunters shoot before the town gets to discuss.
+ */
+func (e *Engine) resolveNight(now time.Time) []Event {
+	out := []Event{Announcement{Text: "Townpeople, its time to wake up and listen to the news"}}
+
+	var saved []Event
+	for _, p := range e.protections {
+		if p.target == e.werewolfTarget {
+			saved = append(saved, Announcement{Text: p.news})
+		}
+	}
+
+	if e.werewolfTarget == "" {
+		out = append(out, Announcement{Text: "Townspeople, the werewolf did not feed tonight"})
+	} else if len(saved) > 0 {
+		out = append(out, saved...)
+	} else {
+		out = append(out, Announcement{Text: fmt.Sprintf("The werewolf chose to kill %v", e.werewolfTarget)})
+		out = append(out, e.kill(e.werewolfTarget)...)
+	}
+
+	for _, name := range e.poisoned {
+		out = append(out, Announcement{Text: fmt.Sprintf("%v was found poisoned", name)})
+		out = append(out, e.kill(name)...)
+	}
+
+	// reset the protected and poisoned players for the next round
+	e.protections = nil
+	e.lastGuarded = e.guarded
+	e.guarded = nil
+	e.poisoned = nil
+	e.werewolfTarget = ""
+	e.werewolfVotes.PrintVotes()
+	e.werewolfVotes.ClearVotes()
+
+	return append(out, e.afterDeaths(now, e.enterTownDiscussion)...)
+}
+
+func (e *Engine) enterTownDiscussion(now time.Time) []Event {
+	// Skip to end stage if the number of users equal to 1, when only werewolves
+	// or no werewolves remain or when the lovers are left alone.
+	alive, werewolves := countAlive(e.players), e.countTeam(Werewolves)
+	if alive <= 1 || alive == werewolves || werewolves == 0 || e.loversAlone() {
+		return e.enterEnd(now)
+	}
+
+	return []Event{
+		e.enter(TownDiscussion, now, e.cfg.TownspersonDiscussion),
+		Announcement{Text: fmt.Sprintf("You have %v time to discuss", e.cfg.TownspersonDiscussion)},
+	}
+}
+
+func (e *Engine) enterTownVote(now time.Time) []Event {
+	names := e.aliveNames()
+	e.townVotes = data.NewVoters(names)
+
+	out := []Event{
+		e.enter(TownVote, now, e.cfg.VotingDuration),
+		Announcement{Text: "Townpeople, now its time for you to vote"},
+		Announcement{Text: fmt.Sprintf("You have %v time to vote", e.cfg.VotingDuration)},
+	}
+
+	for _, id := range e.alivePlayers() {
+		out = append(out, PrivateMessage{Player: id,
+			Text: "Choose the player to kick out with /vote <name>: " + strings.Join(names, ",")})
+	}
+
+	return out
+}
+
+func (e *Engine) resolveTownVote(now time.Time) []Event {
+	kicked := e.townVotes.GetMaxVotedUser()
+	out := []Event{VoteResult{Votes: e.townVotes.Votes(), Eliminated: kicked}}
+
+	if kicked == "" {
+		out = append(out, Announcement{Text: "The town could not reach a consensus. No one was kicked"})
+	} else {
+		out = append(out, Announcement{Text: fmt.Sprintf("The town has chosen to kill %v", kicked)})
+		out = append(out, e.kill(kicked)...)
+	}
+
+	e.townVotes.PrintVotes()
+
+	return append(out, e.afterDeaths(now, e.enterEnd)...)
+}
+
+/*
+ * Gives every hunter who just died a window to shoot, one after the other,
+ * before carrying on with next.
+ */
+func (e *Engine) afterDeaths(now time.Time, next func(now time.Time) []Event) []Event {
+	if len(e.hunters) == 0 {
+		return next(now)
+	}
+
+	e.hunter, e.hunters = e.hunters[0], e.hunters[1:]
+	e.resume = next
+
+	name := e.players[e.hunter].Name
+	names := slices.DeleteFunc(e.aliveNames(), func(alive string) bool { return alive == name })
+	return []Event{
+		e.enter(HunterShot, now, e.cfg.HunterDuration),
+		Announcement{Text: fmt.Sprintf("%v was the hunter and takes aim with their last breath...", name)},
+		PrivateMessage{Player: e.hunter, Text: "Choose the player to shoot with /shoot <name>: " + strings.Join(names, ",")},
+	}
+}
+
+/*
+ * Game win scenario. If no werewolf or townperson is left the game ends,
+ * otherwise the next night begins.
+ */
+func (e *Engine) enterEnd(now time.Time) []Event {
+	var winner string
+	werewolves, town := e.countTeam(Werewolves) > 0, e.countTeam(Village) > 0
+	switch {
+	case e.loversAlone() && werewolves && town:
+		winner = LoversWin
+	case !town && werewolves:
+		winner = WerewolvesWin
+	case !werewolves && town:
+		winner = TownspeopleWin
+	case !werewolves && !town:
+		winner = EveryoneDied
+	default:
+		return e.enterWerewolfDiscuss(now)
+	}
+
+	e.rematch = make(map[string]bool)
+
+	return []Event{
+		e.enter(End, now, e.cfg.RematchDuration),
+		Announcement{Text: "**GAME OVER**"},
+		Announcement{Text: winner},
+		GameOver{Winner: winner},
+		Announcement{Text: fmt.Sprintf("Type /rematch within %v to play again.", e.cfg.RematchDuration)},
+	}
+}
+
+/*
+ * Marks a player as dead based on the username. A dead lover's partner
+ * dies of grief right away.
+ */
+func (e *Engine) kill(name string) []Event {
+	for _, id := range e.order {
+		if player := e.players[id]; player.Name == name && player.Status {
+			player.Status = false
+			out := []Event{PlayerKilled{Player: id, Name: name}}
+			if role := e.roleOf(id); role != nil {
+				out = append(out, role.Dies(e, id)...)
+			}
+			if partner := e.partnerOf(id); partner != "" && e.players[partner].Status {
+				grieving := e.players[partner].Name
+				out = append(out, Announcement{Text: fmt.Sprintf("%v died of grief", grieving)})
+				out = append(out, e.kill(grieving)...)
+			}
+			return out
+		}
+	}
+
+	return nil
+}
+
+// Returns the id of the player the given one is in love with, if any.
+func (e *Engine) partnerOf(id string) string {
+	return e.lovers[id]
+}
+
+// Checks whether two lovers are the last players alive.
+func (e *Engine) loversAlone() bool {
+	alive := e.alivePlayers()
+	return len(alive) == 2 && e.lovers[alive[0]] == alive[1]
+}
+
+/*
+ * Returns the deck the next game is dealt from: the one chosen for the room
+ * or else the preset meant for the number of players.
+ */
+func (e *Engine) deck() (Deck, error) {
+	if e.cfg.Deck != nil {
+		return *e.cfg.Deck, nil
+	}
+
+	return PresetFor(e.cfg.Presets, len(e.players))
+}
+
+/*
+ * Set up roles before initiating the game. Roles of the deck are dealt in
+ * order to a random permutation of the players.
+ */
+func (e *Engine) assignRoles(deck Deck) []Event {
+	roles, balance := deck.deal(len(e.order)), deck.Balance(len(e.order))
+	e.logger.Info("dealing roles", "deck", deck.String(), "balance", balance)
+
+	out := []Event{Announcement{Text: fmt.Sprintf("Roles are dealt from %v (balance %+d)", deck, balance)}}
+	for i, n := range e.rand.Perm(len(e.order)) {
+		player := e.players[e.order[n]]
+		player.Role = roles[i]
+
+		e.logger.Info(player.Name + " has been assigned to be a " + player.Role)
+	}
+
+	for _, id := range e.order {
+		out = append(out, RoleAssigned{Player: id, Role: e.players[id].Role})
+	}
+
+	return out
+}
+
+/*
+ * Returns ids of players allowed to talk in the current state.
+ */
+func (e *Engine) allowedPlayers() []string {
+	if e.state == HunterShot {
+		// The window belongs to the dying hunter alone.
+		return nil
+	} else if isNightState(e.state) {
+		return e.awakePlayers()
+	}
+
+	return slices.Clone(e.order)
+}
+
+// Returns the given ids without the one of the sender.
+func (e *Engine) othersThan(sender string, ids []string) []string {
+	var to []string
+	for _, id := range ids {
+		// dont send message to the place where it came from.
+		if id != sender {
+			to = append(to, id)
+		}
+	}
+
+	return to
+}
+
+/*
+ * Returns ids of alive players.
+ */
+func (e *Engine) alivePlayers() []string {
+	var ids []string
+	for _, id := range e.order {
+		if e.players[id].Status {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// Returns the id of the alive player with the given username.
+func (e *Engine) alivePlayerByName(name string) string {
+	for _, id := range e.alivePlayers() {
+		if e.players[id].Name == name {
+			return id
+		}
+	}
+
+	return ""
+}
+
+/*
+ * Returns list of usernames that are alive.
+ */
+func (e *Engine) aliveNames() []string {
+	var names []string
+	for _, id := range e.alivePlayers() {
+		names = append(names, e.players[id].Name)
+	}
+
+	return names
+}
@@ -0,0 +1,275 @@
+package game
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestShootWithoutHunter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	e := NewEngine(DefaultConfig(), now)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		e.Handle(Join{Player: name, Name: name})
+	}
+
+	// The hunter already fired, the state lasts until the tick ends it.
+	e.state, e.hunter = HunterShot, ""
+	for _, player := range []string{"", "unknown", "a"} {
+		out := e.Handle(Shoot{Player: player, Target: "b"})
+		if len(out) != 1 {
+			t.Fatalf("shot by %q = %v", player, out)
+		}
+		if _, ok := out[0].(Rejected); !ok {
+			t.Errorf("shot by %q = %v, want it rejected", player, out)
+		}
+	}
+}
+
+func TestDeadRolesStillWakeUp(t *testing.T) {
+	for _, tc := range []struct {
+		role     string
+		state    State
+		duration time.Duration
+		wake     string
+	}{
+		{Seer, SeerInspect, DefaultConfig().SeerDuration, "Seer, now its time to wake up and have a vision"},
+		{Bodyguard, BodyguardProtect, DefaultConfig().BodyguardDuration, "Bodyguard, now its time to wake up and protect someone"},
+		{Witch, WitchHeal, DefaultConfig().WitchHealDuration, "Witch, now its time to wake up"},
+	} {
+		cfg := DefaultConfig()
+		deck := mustParseDeck("1 werewolf, 1 " + tc.role + ", rest villager")
+		cfg.Deck = &deck
+
+		now := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+		e := NewEngine(cfg, now)
+		for _, name := range []string{"a", "b", "c", "d"} {
+			e.Handle(Join{Player: name, Name: name})
+		}
+		now = e.Deadline()
+		e.Handle(Tick{Now: now})
+
+		for _, id := range e.Players() {
+			if e.Player(id).Role == tc.role {
+				e.kill(e.Player(id).Name)
+			}
+		}
+
+		// The night goes on as if the dead player was still there.
+		now = e.Deadline()
+		out := e.Handle(Tick{Now: now})
+		if e.State() != tc.state || !e.Deadline().Equal(now.Add(tc.duration)) {
+			t.Fatalf("state = %v until %v, want %v for %v", e.State(), e.Deadline(), tc.state, tc.duration)
+		}
+		if !slices.Contains(out, Event(Announcement{Text: tc.wake})) {
+			t.Errorf("events = %v, want the %v to be woken up", out, tc.role)
+		}
+		for _, event := range out {
+			if announcement, ok := event.(Announcement); ok && announcement.Text != tc.wake {
+				t.Errorf("%v announced %q", tc.state, announcement.Text)
+			}
+		}
+	}
+}
+
+func TestRolesDealtTwice(t *testing.T) {
+	cfg := DefaultConfig()
+	deck := mustParseDeck("1 werewolf, 2 cupids, 2 seers, 2 witches, rest villager")
+	cfg.Deck = &deck
+
+	now := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	e := NewEngine(cfg, now)
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		e.Handle(Join{Player: name, Name: name})
+	}
+	rejected := func(out []Event) bool {
+		return slices.ContainsFunc(out, func(event Event) bool {
+			_, ok := event.(Rejected)
+			return ok
+		})
+	}
+	now = e.Deadline()
+	e.Handle(Tick{Now: now})
+	dealt := make(map[string][]string)
+	for _, id := range e.Players() {
+		dealt[e.Player(id).Role] = append(dealt[e.Player(id).Role], id)
+	}
+
+	// Each cupid shoots once, at players nobody else loves.
+	if e.State() != CupidLink {
+		t.Fatalf("state = %v, want %v", e.State(), CupidLink)
+	}
+	first, second := dealt[Cupid][0], dealt[Cupid][1]
+	if out := e.Handle(Link{Player: first, First: "a", Second: "b"}); rejected(out) {
+		t.Errorf("first cupid: %v", out)
+	}
+	if e.Deadline().Equal(now) {
+		t.Errorf("the night went on before the second cupid shot")
+	}
+	if out := e.Handle(Link{Player: second, First: "a", Second: "c"}); !rejected(out) {
+		t.Errorf("a fell in love twice: %v", out)
+	}
+	if out := e.Handle(Link{Player: second, First: "c", Second: "d"}); rejected(out) {
+		t.Errorf("second cupid: %v", out)
+	}
+	if out := e.Handle(Link{Player: first, First: "e", Second: "f"}); !rejected(out) {
+		t.Errorf("first cupid shot twice: %v", out)
+	}
+	if e.partnerOf("a") != "b" || e.partnerOf("d") != "c" {
+		t.Errorf("lovers = %v", e.lovers)
+	}
+
+	for e.State() != SeerInspect {
+		now = e.Deadline()
+		e.Handle(Tick{Now: now})
+	}
+	// Both seers have a vision, once.
+	for _, id := range dealt[Seer] {
+		if out := e.Handle(Inspect{Player: id, Target: dealt[Werewolf][0]}); rejected(out) {
+			t.Errorf("seer %v: %v", id, out)
+		}
+		if out := e.Handle(Inspect{Player: id, Target: dealt[Townsperson][0]}); !rejected(out) {
+			t.Errorf("seer %v had a second vision: %v", id, out)
+		}
+	}
+
+	for e.State() != WitchHeal {
+		now = e.Deadline()
+		e.Handle(Tick{Now: now})
+	}
+	// Each witch has potions of her own.
+	for i, id := range dealt[Witch] {
+		if out := e.Handle(Poison{Player: id, Target: dealt[Seer][i]}); rejected(out) {
+			t.Errorf("witch %v: %v", id, out)
+		}
+		if out := e.Handle(Poison{Player: id, Target: dealt[Werewolf][0]}); !rejected(out) {
+			t.Errorf("witch %v poisoned twice: %v", id, out)
+		}
+	}
+}
+
+// Starts a game of four players, a to d, dealt from the given deck.
+func startGame(t *testing.T, deck string) *Engine {
+	t.Helper()
+
+	cfg := DefaultConfig()
+	parsed := mustParseDeck(deck)
+	cfg.Deck = &parsed
+
+	e := NewEngine(cfg, time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC))
+	for _, name := range []string{"a", "b", "c", "d"} {
+		e.Handle(Join{Player: name, Name: name})
+	}
+	e.Handle(Tick{Now: e.Deadline()})
+	if e.State() == Connect {
+		t.Fatalf("the game did not start")
+	}
+
+	return e
+}
+
+// Lets states expire until the given one, returning every event on the way.
+func playUntil(t *testing.T, e *Engine, state State) []Event {
+	t.Helper()
+
+	var out []Event
+	for e.State() != state {
+		if e.State() == End {
+			t.Fatalf("the game ended before %v: %v", state, out)
+		}
+		out = append(out, e.Handle(Tick{Now: e.Deadline()})...)
+	}
+
+	return out
+}
+
+// Returns the ids of the living players, the werewolves first.
+func wolvesAndTown(e *Engine) (wolves, town []string) {
+	for _, id := range e.alivePlayers() {
+		if e.roleOf(id).Team() == Werewolves {
+			wolves = append(wolves, id)
+		} else {
+			town = append(town, id)
+		}
+	}
+
+	return wolves, town
+}
+
+// Has every living player vote for the given one.
+func voteFor(e *Engine, target string) {
+	for _, id := range e.alivePlayers() {
+		e.Handle(Vote{Player: id, Target: e.Player(target).Name})
+	}
+}
+
+func TestWerewolvesWin(t *testing.T) {
+	e := startGame(t, "1 werewolf, rest villager")
+	wolves, town := wolvesAndTown(e)
+
+	// The werewolf feeds, the town hangs one of its own.
+	playUntil(t, e, WerewolfVote)
+	e.Handle(Vote{Player: wolves[0], Target: e.Player(town[0]).Name})
+	out := playUntil(t, e, TownDiscussion)
+	if !slices.Contains(out, Event(PlayerKilled{Player: town[0], Name: e.Player(town[0]).Name})) {
+		t.Fatalf("%v survived the night: %v", town[0], out)
+	}
+	playUntil(t, e, TownVote)
+	voteFor(e, town[1])
+
+	// The last townsperson is eaten the next night.
+	playUntil(t, e, WerewolfVote)
+	if e.Player(town[1]).Status {
+		t.Fatalf("%v survived the town vote", town[1])
+	}
+	e.Handle(Vote{Player: wolves[0], Target: e.Player(town[2]).Name})
+	out = playUntil(t, e, End)
+	if !slices.Contains(out, Event(GameOver{Winner: WerewolvesWin})) {
+		t.Errorf("game over = %v, want %v", out, WerewolvesWin)
+	}
+}
+
+func TestTownWins(t *testing.T) {
+	e := startGame(t, "1 werewolf, rest villager")
+
+	// The werewolf does not feed, the town hangs the werewolf.
+	playUntil(t, e, TownVote)
+	wolves, town := wolvesAndTown(e)
+	if len(town) != 3 {
+		t.Fatalf("%d townspeople alive after a night without a victim", len(town))
+	}
+	voteFor(e, wolves[0])
+	out := e.Handle(Tick{Now: e.Deadline()})
+	if e.State() != End || !slices.Contains(out, Event(GameOver{Winner: TownspeopleWin})) {
+		t.Errorf("state = %v after %v, want %v", e.State(), out, TownspeopleWin)
+	}
+}
+
+func TestHealSavesVictim(t *testing.T) {
+	e := startGame(t, "1 werewolf, 1 witch, rest villager")
+
+	playUntil(t, e, WerewolfVote)
+	wolves, town := wolvesAndTown(e)
+	victim := slices.IndexFunc(town, func(id string) bool { return e.Player(id).Role != Witch })
+	e.Handle(Vote{Player: wolves[0], Target: e.Player(town[victim]).Name})
+
+	playUntil(t, e, WitchHeal)
+	for _, id := range town {
+		if e.Player(id).Role == Witch {
+			if out := e.Handle(Heal{Player: id, Target: e.Player(town[victim]).Name}); len(out) != 0 {
+				t.Fatalf("heal = %v", out)
+			}
+		}
+	}
+
+	out := playUntil(t, e, TownDiscussion)
+	if !e.Player(town[victim]).Status {
+		t.Errorf("%v was killed despite the heal", town[victim])
+	}
+	if !slices.Contains(out, Event(Announcement{Text: "The witch saved a person from being killed"})) {
+		t.Errorf("night news = %v, want the witch's save", out)
+	}
+	if slices.ContainsFunc(out, func(event Event) bool { _, ok := event.(PlayerKilled); return ok }) {
+		t.Errorf("someone died during the night: %v", out)
+	}
+}
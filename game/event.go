@@ -0,0 +1,88 @@
+package game
+
+import "time"
+
+/*
+ * Event is produced by the engine in response to an input. Adapters decide
+ * how (and to whom) each event is delivered.
+ */
+type Event interface {
+	event()
+}
+
+// Announcement is a public message for every connected player.
+type Announcement struct {
+	Text string
+}
+
+// Reply answers the player whose input produced it.
+type Reply struct {
+	Player string
+	Text   string
+}
+
+// Rejected answers the player whose input the game refused, saying why.
+type Rejected struct {
+	Player string
+	Text   string
+}
+
+// PrivateMessage is a message for a single player.
+type PrivateMessage struct {
+	Player string
+	Text   string
+}
+
+// Chat is a line typed by a player that must be relayed to the To players.
+// Channel is TownChannel, or the role whose players talk at night.
+type Chat struct {
+	From    string
+	Name    string
+	To      []string
+	Channel string
+	Text    string
+}
+
+// Channel of the chat everyone may hear.
+const TownChannel = "town"
+
+// RoleAssigned tells a player which role they received.
+type RoleAssigned struct {
+	Player string
+	Role   string
+}
+
+// PhaseChanged is emitted every time the engine enters a new state.
+type PhaseChanged struct {
+	State    State
+	Deadline time.Time
+}
+
+// PlayerKilled is emitted when a player dies.
+type PlayerKilled struct {
+	Player string
+	Name   string
+}
+
+// VoteResult tells how many votes every player got once the town voted.
+// Eliminated is empty when the town did not agree on anyone.
+type VoteResult struct {
+	Votes      map[string]int
+	Eliminated string
+}
+
+// GameOver is emitted once a winner has been decided.
+type GameOver struct {
+	Winner string
+}
+
+func (Announcement) event()   {}
+func (Reply) event()          {}
+func (Rejected) event()       {}
+func (PrivateMessage) event() {}
+func (Chat) event()           {}
+func (RoleAssigned) event()   {}
+func (PhaseChanged) event()   {}
+func (PlayerKilled) event()   {}
+func (VoteResult) event()     {}
+func (GameOver) event()       {}
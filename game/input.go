@@ -0,0 +1,110 @@
+package game
+
+import "time"
+
+/*
+ * Input is anything the engine can consume. Every input that comes from a
+ * player carries the opaque player id the adapter registered at Join.
+ */
+type Input interface {
+	input()
+}
+
+// Join adds a player to the game while it is still accepting connections.
+type Join struct {
+	Player string
+	Name   string
+}
+
+// Leave removes a player from the game.
+type Leave struct {
+	Player string
+}
+
+// Say is free text typed by a player. It is only ever relayed as chat,
+// actions have inputs of their own. The chat is signed with the name the
+// player joined with.
+type Say struct {
+	Player string
+	Text   string
+}
+
+// Vote casts a vote against Target during a voting phase.
+type Vote struct {
+	Player string
+	Target string
+}
+
+// Heal asks the witch's potion to save Target. An empty Target passes.
+type Heal struct {
+	Player string
+	Target string
+}
+
+// Poison asks the witch's poison potion to kill Target.
+type Poison struct {
+	Player string
+	Target string
+}
+
+// Protect asks the bodyguard to watch over Target for the night.
+type Protect struct {
+	Player string
+	Target string
+}
+
+// Inspect asks the seer's vision whether Target is a werewolf.
+type Inspect struct {
+	Player string
+	Target string
+}
+
+// Shoot is the last act of a dying hunter, taking Target down as well.
+type Shoot struct {
+	Player string
+	Target string
+}
+
+// Link is Cupid's arrow, making First and Second fall in love.
+type Link struct {
+	Player string
+	First  string
+	Second string
+}
+
+// Rematch asks for a new game once the current one is over.
+type Rematch struct {
+	Player string
+}
+
+// Offline tells the engine that a player lost their connection. They keep
+// their seat, but the game may stop waiting for them.
+type Offline struct {
+	Player string
+}
+
+// Online tells the engine that an offline player is back.
+type Online struct {
+	Player string
+}
+
+// Tick lets the engine know what time it is so that it can move past
+// phase deadlines.
+type Tick struct {
+	Now time.Time
+}
+
+func (Join) input()    {}
+func (Leave) input()   {}
+func (Say) input()     {}
+func (Vote) input()    {}
+func (Heal) input()    {}
+func (Poison) input()  {}
+func (Inspect) input() {}
+func (Protect) input() {}
+func (Shoot) input()   {}
+func (Link) input()    {}
+func (Rematch) input() {}
+func (Offline) input() {}
+func (Online) input()  {}
+func (Tick) input()    {}
@@ -0,0 +1,88 @@
+package game
+
+import (
+	"slices"
+	"werewolves-go/data"
+)
+
+// What the game does about players who lost their connection.
+const (
+	// Every state lasts until its deadline, as if everyone was there.
+	WaitForOffline = "wait"
+	// A state ends as soon as everyone who has to act in it is offline, the
+	// game runs at its normal pace while no living player is online.
+	SkipOffline = "skip"
+	// Offline players abstain, a vote ends once everyone online voted.
+	AbstainOffline = "abstain"
+)
+
+// Returns the ways the game can deal with offline players.
+func OfflinePolicies() []string {
+	return []string{WaitForOffline, SkipOffline, AbstainOffline}
+}
+
+/*
+ * Ends the current state right away when the policy for offline players
+ * says there is nobody left to wait for. States entered while ticking are
+ * only cut short once the tick is over, so that a game nobody is playing
+ * moves one state at a time instead of going round forever.
+ */
+func (e *Engine) stopWaiting() {
+	if e.state == Closed || !e.deadline.After(e.now) {
+		return
+	}
+
+	var done bool
+	switch e.cfg.Offline {
+	case SkipOffline:
+		actors := e.actors()
+		done = len(actors) > 0 && !slices.ContainsFunc(actors, e.isOnline) &&
+			slices.ContainsFunc(e.alivePlayers(), e.isOnline)
+	case AbstainOffline:
+		if voters := e.currentVoters(); voters != nil {
+			done = !slices.ContainsFunc(e.actors(), func(id string) bool {
+				return e.isOnline(id) && !voters.HasVoted(e.players[id].Name)
+			})
+		}
+	}
+
+	if done {
+		e.logger.Info("no online player left to wait for", "state", e.state, "policy", e.cfg.Offline)
+		e.deadline = e.now
+	}
+}
+
+/*
+ * Returns ids of the living players who have to act in the current state.
+ */
+func (e *Engine) actors() []string {
+	switch {
+	case e.state == HunterShot:
+		if e.hunter == "" {
+			return nil
+		}
+		return []string{e.hunter}
+	case e.state == TownDiscussion || e.state == TownVote:
+		return e.alivePlayers()
+	case isNightState(e.state):
+		return slices.DeleteFunc(e.awakePlayers(), func(id string) bool { return !e.players[id].Status })
+	}
+
+	return nil
+}
+
+// Returns the votes cast in the current state, nil outside of votes.
+func (e *Engine) currentVoters() *data.Voters {
+	switch e.state {
+	case WerewolfVote:
+		return e.werewolfVotes
+	case TownVote:
+		return e.townVotes
+	}
+
+	return nil
+}
+
+func (e *Engine) isOnline(id string) bool {
+	return !e.offline[id]
+}
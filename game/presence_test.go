@@ -0,0 +1,61 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOfflinePlayersAbstain(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MinPlayers = 3
+	cfg.Offline = AbstainOffline
+	deck := mustParseDeck("1 werewolf, rest villager")
+	cfg.Deck = &deck
+
+	now := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	e := NewEngine(cfg, now)
+	for _, name := range []string{"a", "b", "c"} {
+		e.Handle(Join{Player: name, Name: name})
+	}
+
+	// Nobody feeds on the first night, then the town gets to vote.
+	for e.State() != TownVote {
+		now = e.Deadline()
+		e.Handle(Tick{Now: now})
+	}
+
+	e.Handle(Offline{Player: "c"})
+	e.Handle(Vote{Player: "a", Target: "c"})
+	if !e.Deadline().After(now) {
+		t.Fatal("vote ended before every online player voted")
+	}
+	e.Handle(Vote{Player: "b", Target: "c"})
+	if !e.Deadline().Equal(now) {
+		t.Errorf("vote still waits for the offline player until %v", e.Deadline())
+	}
+}
+
+func TestEveryoneOfflineSkipped(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Offline = SkipOffline
+	deck := mustParseDeck("1 werewolf, 1 seer, rest villager")
+	cfg.Deck = &deck
+
+	now := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	e := NewEngine(cfg, now)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		e.Handle(Join{Player: name, Name: name})
+	}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		e.Handle(Offline{Player: name})
+	}
+
+	// Nobody is left to play, the states last until their deadline.
+	for i := 0; i < 10; i++ {
+		now = e.Deadline()
+		e.Handle(Tick{Now: now})
+		if !e.Deadline().After(now) {
+			t.Fatalf("%v was cut short with every player offline", e.State())
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+ * Preset is the deck dealt to games of at least MinPlayers players, until
+ * a preset with a higher MinPlayers takes over.
+ */
+type Preset struct {
+	MinPlayers int
+	Deck       Deck
+}
+
+/*
+ * Returns the built-in presets for 4 to 20 players. Each one keeps the
+ * balance score of the games it is used for within a few points of zero.
+ */
+func DefaultPresets() []Preset {
+	return []Preset{
+		{MinPlayers: 4, Deck: mustParseDeck("1 werewolf, 1 seer")},
+		{MinPlayers: 5, Deck: mustParseDeck("1 werewolf, 1 seer, 1 cupid")},
+		{MinPlayers: 8, Deck: mustParseDeck("2 werewolves, 1 seer, 1 witch, 1 cupid")},
+		{MinPlayers: 12, Deck: mustParseDeck("3 werewolves, 1 seer, 1 witch, 1 hunter, 1 cupid")},
+		{MinPlayers: 15, Deck: mustParseDeck("3 werewolves, 1 seer, 1 witch, 1 hunter, 1 bodyguard, 1 cupid")},
+		{MinPlayers: 17, Deck: mustParseDeck("4 werewolves, 1 seer, 1 witch, 1 hunter, 1 bodyguard, 1 cupid")},
+	}
+}
+
+/*
+ * Returns the deck of the preset meant for the given number of players.
+ */
+func PresetFor(presets []Preset, players int) (Deck, error) {
+	sorted := append([]Preset(nil), presets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].MinPlayers > sorted[j].MinPlayers })
+
+	for _, preset := range sorted {
+		if players >= preset.MinPlayers {
+			return preset.Deck, nil
+		}
+	}
+
+	return Deck{}, fmt.Errorf("No role preset is meant for %d players.", players)
+}
+
+/*
+ * Returns the balance score of a game of the given number of players dealt
+ * from the deck: the sum of the weights of every dealt role. Positive
+ * scores favour the village, negative ones the werewolves.
+ */
+func (d Deck) Balance(players int) int {
+	score := 0
+	for _, name := range d.deal(players) {
+		if role, ok := LookupRole(name); ok {
+			score += role.Weight()
+		}
+	}
+
+	return score
+}
+
+func mustParseDeck(spec string) Deck {
+	deck, err := ParseDeck(spec)
+	if err != nil {
+		panic(err)
+	}
+
+	return deck
+}
@@ -0,0 +1,23 @@
+package game
+
+import "testing"
+
+func TestDefaultPresetsAreBalanced(t *testing.T) {
+	presets := DefaultPresets()
+	for players := 4; players <= 20; players++ {
+		deck, err := PresetFor(presets, players)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := deck.Check(players); err != nil {
+			t.Errorf("%d players: %v", players, err)
+		}
+		if balance := deck.Balance(players); balance < -4 || balance > 4 {
+			t.Errorf("%d players: %v has balance %+d", players, deck, balance)
+		}
+	}
+
+	if _, err := PresetFor(presets, 3); err == nil {
+		t.Error("expected no preset for 3 players")
+	}
+}
@@ -0,0 +1,277 @@
+package game
+
+import (
+	"slices"
+	"sort"
+)
+
+/*
+ * Team a role plays for. The game is won by the last team standing.
+ */
+type Team int
+
+const (
+	Village Team = iota
+	Werewolves
+)
+
+func (t Team) String() string {
+	switch t {
+	case Village:
+		return "village"
+	case Werewolves:
+		return "werewolves"
+	}
+
+	return "unknown"
+}
+
+/*
+ * Role describes what a player holding it does. New roles are added by
+ * implementing Role and registering it with RegisterRole, the engine looks
+ * roles up by the name stored in data.Client.Role.
+ */
+type Role interface {
+	// Name under which the role is registered and dealt.
+	Name() string
+	// Team the role counts for when deciding who won.
+	Team() Team
+	// AppearsAs is the team the seer sees when inspecting the role.
+	AppearsAs() Team
+	// Weight is how much the role helps the village, negative when it
+	// helps the werewolves. It makes up the balance score of a deck.
+	Weight() int
+	// Wakes reports whether players holding the role are awake in state.
+	// Only awake players may talk during a night state.
+	Wakes(state State) bool
+	// Act applies an action input of a living player holding the role
+	// while the role is awake. ok is false when the role has no use for
+	// the input, which is then rejected.
+	Act(e *Engine, player string, in Input) (out []Event, ok bool)
+	// Dies applies what happens when a player holding the role dies,
+	// right after the player is marked dead.
+	Dies(e *Engine, player string) []Event
+}
+
+var registry = make(map[string]Role)
+
+/*
+ * Makes a role available to the engine. Registering the same name twice
+ * panics, like registering a database driver twice does.
+ */
+func RegisterRole(role Role) {
+	if _, ok := registry[role.Name()]; ok {
+		panic("game: RegisterRole called twice for role " + role.Name())
+	}
+	registry[role.Name()] = role
+}
+
+// Returns the role registered under the given name.
+func LookupRole(name string) (Role, bool) {
+	role, ok := registry[name]
+	return role, ok
+}
+
+// Returns the names of every registered role, sorted.
+func Roles() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Checks whether any registered role is awake in the given state.
+func isNightState(state State) bool {
+	for _, role := range registry {
+		if role.Wakes(state) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func init() {
+	RegisterRole(townsperson{})
+	RegisterRole(werewolf{})
+	RegisterRole(witch{})
+	RegisterRole(seer{})
+	RegisterRole(hunter{})
+	RegisterRole(cupid{})
+	RegisterRole(bodyguard{})
+}
+
+/*
+ * villager holds the defaults of a role playing for the town that never
+ * wakes up at night.
+ */
+type villager struct{}
+
+func (villager) Team() Team       { return Village }
+func (villager) AppearsAs() Team  { return Village }
+func (villager) Weight() int      { return 1 }
+func (villager) Wakes(State) bool { return false }
+func (villager) Act(*Engine, string, Input) ([]Event, bool) {
+	return nil, false
+}
+func (villager) Dies(*Engine, string) []Event { return nil }
+
+type townsperson struct{ villager }
+
+func (townsperson) Name() string { return Townsperson }
+
+// The hunter sleeps through the night, its shot is taken when it dies.
+type hunter struct{ villager }
+
+func (hunter) Name() string { return Hunter }
+func (hunter) Weight() int  { return 3 }
+
+// A dying hunter gets to shoot once the deaths of the moment are announced.
+func (hunter) Dies(e *Engine, player string) []Event {
+	e.hunters = append(e.hunters, player)
+	return nil
+}
+
+type werewolf struct{}
+
+func (werewolf) Name() string    { return Werewolf }
+func (werewolf) Team() Team      { return Werewolves }
+func (werewolf) AppearsAs() Team { return Werewolves }
+func (werewolf) Weight() int     { return -6 }
+
+func (werewolf) Wakes(state State) bool {
+	return state == WerewolfDiscuss || state == WerewolfVote
+}
+
+// Werewolves discuss freely and only vote once voting starts.
+func (werewolf) Act(e *Engine, player string, in Input) ([]Event, bool) {
+	vote, ok := in.(Vote)
+	if !ok || e.state != WerewolfVote {
+		return nil, false
+	}
+
+	return e.vote(vote, e.werewolfVotes), true
+}
+
+func (werewolf) Dies(*Engine, string) []Event { return nil }
+
+type witch struct{ villager }
+
+func (witch) Name() string { return Witch }
+func (witch) Weight() int  { return 4 }
+
+func (witch) Wakes(state State) bool { return state == WitchHeal }
+
+func (witch) Act(e *Engine, player string, in Input) ([]Event, bool) {
+	switch in := in.(type) {
+	case Heal:
+		return e.heal(in), true
+	case Poison:
+		return e.poison(in), true
+	}
+
+	return nil, false
+}
+
+type seer struct{ villager }
+
+func (seer) Name() string { return Seer }
+func (seer) Weight() int  { return 7 }
+
+func (seer) Wakes(state State) bool { return state == SeerInspect }
+
+func (seer) Act(e *Engine, player string, in Input) ([]Event, bool) {
+	inspect, ok := in.(Inspect)
+	if !ok {
+		return nil, false
+	}
+
+	return e.inspect(inspect), true
+}
+
+type cupid struct{ villager }
+
+func (cupid) Name() string { return Cupid }
+
+// Lovers from both teams may turn against their own, which hurts the village.
+func (cupid) Weight() int { return -3 }
+
+func (cupid) Wakes(state State) bool { return state == CupidLink }
+
+func (cupid) Act(e *Engine, player string, in Input) ([]Event, bool) {
+	link, ok := in.(Link)
+	if !ok {
+		return nil, false
+	}
+
+	return e.link(link), true
+}
+
+type bodyguard struct{ villager }
+
+func (bodyguard) Name() string { return Bodyguard }
+func (bodyguard) Weight() int  { return 3 }
+
+func (bodyguard) Wakes(state State) bool { return state == BodyguardProtect }
+
+func (bodyguard) Act(e *Engine, player string, in Input) ([]Event, bool) {
+	protect, ok := in.(Protect)
+	if !ok {
+		return nil, false
+	}
+
+	return e.protect(protect), true
+}
+
+/*
+ * Returns the role of the given player, nil until roles are dealt.
+ */
+func (e *Engine) roleOf(id string) Role {
+	player, ok := e.players[id]
+	if !ok {
+		return nil
+	}
+
+	return registry[player.Role]
+}
+
+/*
+ * Returns count of alive players whose role plays for the given team.
+ */
+func (e *Engine) countTeam(team Team) int {
+	count := 0
+	for id, player := range e.players {
+		if role := e.roleOf(id); player.Status && role != nil && role.Team() == team {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Returns ids of players whose role is awake in the current state.
+func (e *Engine) awakePlayers() []string {
+	return slices.DeleteFunc(slices.Clone(e.order), func(id string) bool {
+		role := e.roleOf(id)
+		return role == nil || !role.Wakes(e.state)
+	})
+}
+
+// Returns ids of the living players whose role is awake in the given state.
+func (e *Engine) wakingIn(state State) []string {
+	return slices.DeleteFunc(e.alivePlayers(), func(id string) bool {
+		role := e.roleOf(id)
+		return role == nil || !role.Wakes(state)
+	})
+}
+
+// Checks whether a role awake in the given state was dealt, to the living or the dead.
+func (e *Engine) dealt(state State) bool {
+	return slices.ContainsFunc(e.order, func(id string) bool {
+		role := e.roleOf(id)
+		return role != nil && role.Wakes(state)
+	})
+}
@@ -0,0 +1,95 @@
+package game
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// A second role of the werewolves, waking up and voting with them.
+type alphaWolf struct{ werewolf }
+
+func (alphaWolf) Name() string { return "alphawolf" }
+
+func init() {
+	RegisterRole(alphaWolf{})
+}
+
+func TestSecondWerewolfRole(t *testing.T) {
+	cfg := DefaultConfig()
+	deck := mustParseDeck("1 werewolf, 1 alphawolf, rest villager")
+	cfg.Deck = &deck
+
+	now := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	e := NewEngine(cfg, now)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		e.Handle(Join{Player: name, Name: name})
+	}
+
+	// Both werewolves get to discuss, then to vote.
+	now = e.Deadline()
+	e.Handle(Tick{Now: now})
+	if e.State() != WerewolfDiscuss {
+		t.Fatalf("state = %v, want %v", e.State(), WerewolfDiscuss)
+	}
+	now = e.Deadline()
+	e.Handle(Tick{Now: now})
+
+	var wolves []string
+	var victim string
+	for _, id := range e.Players() {
+		if e.roleOf(id).Team() == Werewolves {
+			wolves = append(wolves, id)
+		} else {
+			victim = e.Player(id).Name
+		}
+	}
+	for _, id := range wolves {
+		if out := e.Handle(Vote{Player: id, Target: victim}); len(out) != 0 {
+			t.Errorf("%v voted: %v", e.Player(id).Role, out)
+		}
+	}
+	if votes := e.Votes()[victim]; votes != 2 {
+		t.Errorf("%v has %d votes, want 2", victim, votes)
+	}
+}
+
+// A villager whose death is told to the town.
+type elder struct{ villager }
+
+func (elder) Name() string { return "elder" }
+
+func (elder) Dies(e *Engine, player string) []Event {
+	return []Event{Announcement{Text: "The elder is dead, the village mourns."}}
+}
+
+func init() {
+	RegisterRole(elder{})
+}
+
+func TestDeathHook(t *testing.T) {
+	cfg := DefaultConfig()
+	deck := mustParseDeck("1 werewolf, 1 elder, rest villager")
+	cfg.Deck = &deck
+
+	now := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	e := NewEngine(cfg, now)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		e.Handle(Join{Player: name, Name: name})
+	}
+	e.Handle(Tick{Now: e.Deadline()})
+
+	for _, id := range e.Players() {
+		if e.Player(id).Role != "elder" {
+			continue
+		}
+		out := e.kill(e.Player(id).Name)
+		want := []Event{
+			PlayerKilled{Player: id, Name: e.Player(id).Name},
+			Announcement{Text: "The elder is dead, the village mourns."},
+		}
+		if !slices.Equal(out, want) {
+			t.Errorf("killing the elder = %v, want %v", out, want)
+		}
+	}
+}
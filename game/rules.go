@@ -0,0 +1,51 @@
+package game
+
+import (
+	"werewolves-go/data"
+)
+
+/*
+ * Names of the built-in roles, see role.go.
+ */
+const (
+	Werewolf    = "werewolf"
+	Witch       = "witch"
+	Seer        = "seer"
+	Hunter      = "hunter"
+	Cupid       = "cupid"
+	Bodyguard   = "bodyguard"
+	Townsperson = "townsperson"
+)
+
+/*
+ * Winners reported by GameOver.
+ */
+const (
+	WerewolvesWin  = "Werewolves win"
+	TownspeopleWin = "Townspeople win"
+	EveryoneDied   = "Everyone died"
+	LoversWin      = "The lovers win"
+)
+
+/*
+ * Returns count of alive players.
+ */
+func countAlive(players map[string]*data.Client) int {
+	count := 0
+	for _, player := range players {
+		if player.Status {
+			count++
+		}
+	}
+
+	return count
+}
+
+/*
+ * protection shields a player from the werewolves for one night. news is
+ * what the town is told when it saved the werewolves' victim.
+ */
+type protection struct {
+	target string
+	news   string
+}
@@ -0,0 +1,60 @@
+package game
+
+/*
+ * State defines the phases of a werewolf game.
+ */
+type State int
+
+/*
+ * Constants to define states of the werewolf game.
+ */
+const (
+	Connect State = iota
+	Start
+	CupidLink
+	WerewolfDiscuss
+	WerewolfVote
+	SeerInspect
+	BodyguardProtect
+	WitchHeal
+	TownDiscussion
+	TownVote
+	HunterShot
+	End
+	Closed
+	SLen = iota
+)
+
+// Enum to string
+func (state State) String() string {
+	switch state {
+	case Connect:
+		return "connect"
+	case Start:
+		return "start"
+	case CupidLink:
+		return "cupidlink"
+	case WerewolfDiscuss:
+		return "werewolfdiscuss"
+	case WerewolfVote:
+		return "werewolfvote"
+	case SeerInspect:
+		return "seerinspect"
+	case BodyguardProtect:
+		return "bodyguardprotect"
+	case WitchHeal:
+		return "witchheal"
+	case TownDiscussion:
+		return "townpersondiscussion"
+	case TownVote:
+		return "townspersonvote"
+	case HunterShot:
+		return "huntershot"
+	case End:
+		return "end"
+	case Closed:
+		return "closed"
+	default:
+		return ""
+	}
+}
@@ -1,21 +1,27 @@
 module werewolves-go
 
-go 1.21
+go 1.22
 
 require (
 	github.com/anthdm/hollywood v0.0.0-20240115210651-dd34702ee21f
+	github.com/gdamore/tcell/v2 v2.6.0
+	github.com/mattn/go-runewidth v0.0.14
+	golang.org/x/net v0.17.0
 	google.golang.org/protobuf v1.32.0
 )
 
 require (
 	github.com/DataDog/gostackparse v0.7.0 // indirect
+	github.com/gdamore/encoding v1.0.0 // indirect
 	github.com/golang/protobuf v1.5.3 // indirect
 	github.com/klauspost/cpuid/v2 v2.0.9 // indirect
+	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
 	github.com/planetscale/vtprotobuf v0.5.0 // indirect
+	github.com/rivo/uniseg v0.4.3 // indirect
 	github.com/zeebo/errs v1.2.2 // indirect
 	github.com/zeebo/xxh3 v1.0.2 // indirect
-	golang.org/x/net v0.17.0 // indirect
 	golang.org/x/sys v0.15.0 // indirect
+	golang.org/x/term v0.15.0 // indirect
 	golang.org/x/text v0.13.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20231002182017-d307bd883b97 // indirect
 	google.golang.org/grpc v1.60.1 // indirect
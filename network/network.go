@@ -0,0 +1,91 @@
+/*
+ * Package network connects actor engines over the network. A process may
+ * listen on one address, e.g. every interface of a container, and be known
+ * to its peers under another one, e.g. the address of the host or of the
+ * NAT in front of it.
+ */
+package network
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/anthdm/hollywood/remote"
+)
+
+/*
+ * advertisedRemote is a remote whose actors are addressed by the advertise
+ * address instead of the one the remote listens on. PIDs handed to peers
+ * carry that address, so replies find their way back.
+ */
+type advertisedRemote struct {
+	*remote.Remote
+	advertise string
+}
+
+// Returns the address peers use to reach the remote.
+func (r advertisedRemote) Address() string {
+	return r.advertise
+}
+
+/*
+ * Returns a remote that listens on listen and that peers reach at
+ * advertise. Both are "host:port" addresses.
+ */
+func NewRemote(listen, advertise string) actor.Remoter {
+	return advertisedRemote{Remote: remote.New(listen, remote.NewConfig()), advertise: advertise}
+}
+
+/*
+ * Returns a "host:port" address for the given one, which may also be a
+ * bare port. Bare ports are bound to the given default host.
+ */
+func ListenAddress(address, host string) (string, error) {
+	if _, err := strconv.Atoi(address); err == nil {
+		address = net.JoinHostPort(host, address)
+	}
+
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", fmt.Errorf("%q is not a host:port address", address)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("%q does not have a valid port", address)
+	}
+
+	return address, nil
+}
+
+/*
+ * Returns the address peers should reach a process listening on listen at.
+ * An explicit advertise address wins. Otherwise it is the listen address,
+ * unless that one listens on every interface, in which case the host name
+ * of the machine is used with the listen port.
+ */
+func AdvertiseAddress(listen, advertise string) (string, error) {
+	if advertise != "" {
+		host, _, err := net.SplitHostPort(advertise)
+		if err != nil || host == "" || net.ParseIP(host).IsUnspecified() {
+			return "", fmt.Errorf("advertise address %q must name the host peers connect to", advertise)
+		}
+		return advertise, nil
+	}
+
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "", fmt.Errorf("%q is not a host:port address", listen)
+	}
+	if host != "" && !net.ParseIP(host).IsUnspecified() {
+		return listen, nil
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("listening on every interface needs an advertise address: %w", err)
+	}
+
+	return net.JoinHostPort(hostname, port), nil
+}
@@ -0,0 +1,102 @@
+package network
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+	"werewolves-go/types"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// Returns a port nothing listens on right now.
+func freePort(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	return strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+}
+
+func TestAdvertiseAddress(t *testing.T) {
+	hostname, _ := os.Hostname()
+	for _, tc := range []struct {
+		listen, advertise, want string
+	}{
+		{"127.0.0.1:4000", "", "127.0.0.1:4000"},
+		{"0.0.0.0:4000", "game.example.com:4000", "game.example.com:4000"},
+		{"0.0.0.0:4000", "", net.JoinHostPort(hostname, "4000")},
+		{":4000", "", net.JoinHostPort(hostname, "4000")},
+	} {
+		got, err := AdvertiseAddress(tc.listen, tc.advertise)
+		if err != nil || got != tc.want {
+			t.Errorf("AdvertiseAddress(%q, %q) = %q, %v, want %q", tc.listen, tc.advertise, got, err, tc.want)
+		}
+	}
+
+	for _, advertise := range []string{"0.0.0.0:4000", ":4000", "4000"} {
+		if _, err := AdvertiseAddress("0.0.0.0:4000", advertise); err == nil {
+			t.Errorf("advertise address %q was accepted", advertise)
+		}
+	}
+}
+
+func TestListenAddress(t *testing.T) {
+	if got, err := ListenAddress("4000", "127.0.0.1"); err != nil || got != "127.0.0.1:4000" {
+		t.Errorf("bare port gave %q, %v", got, err)
+	}
+	if got, err := ListenAddress("0.0.0.0:4000", "127.0.0.1"); err != nil || got != "0.0.0.0:4000" {
+		t.Errorf("full address gave %q, %v", got, err)
+	}
+	for _, address := range []string{"localhost", "localhost:http", "localhost:70000"} {
+		if _, err := ListenAddress(address, "127.0.0.1"); err == nil {
+			t.Errorf("listen address %q was accepted", address)
+		}
+	}
+}
+
+// Replies travel to the advertised address of the sender, not its listen one.
+func TestRepliesUseAdvertisedAddress(t *testing.T) {
+	newEngine := func() *actor.Engine {
+		port := freePort(t)
+		e, err := actor.NewEngine(actor.NewEngineConfig().WithRemote(NewRemote("0.0.0.0:"+port, "localhost:"+port)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	server, client := newEngine(), newEngine()
+
+	server.SpawnFunc(func(ctx *actor.Context) {
+		if msg, ok := ctx.Message().(*types.Message); ok {
+			ctx.Send(ctx.Sender(), &types.Message{Username: "server", Msg: "echo " + msg.Msg})
+		}
+	}, "server", actor.WithID("primary"))
+
+	replies := make(chan string, 1)
+	pid := client.SpawnFunc(func(ctx *actor.Context) {
+		if msg, ok := ctx.Message().(*types.Message); ok {
+			replies <- msg.Msg
+		}
+	}, "client")
+
+	if pid.Address != client.Address() || client.Address()[:10] != "localhost:" {
+		t.Fatalf("client pid %v does not carry the advertised address", pid)
+	}
+
+	client.SendWithSender(actor.NewPID(server.Address(), "server/primary"), &types.Message{Msg: "hello"}, pid)
+	select {
+	case reply := <-replies:
+		if reply != "echo hello" {
+			t.Errorf("unexpected reply %q", reply)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the reply never reached the client")
+	}
+}
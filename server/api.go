@@ -0,0 +1,209 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"werewolves-go/types"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// Time the lobby and its rooms have to answer a query of the API.
+const api_timeout time.Duration = 2 * time.Second
+
+// Number of entries kept in the log of a room.
+const log_limit int = 1000
+
+/*
+ * roomQuery asks a room, through the lobby, how its game stands. Admins
+ * also get to see the votes.
+ */
+type roomQuery struct {
+	id    string
+	admin bool
+}
+
+/*
+ * roomView is what the API tells about a room. Votes are only filled in
+ * for admins while a vote is going on.
+ */
+type roomView struct {
+	ID        string         `json:"id"`
+	State     string         `json:"state"`
+	Deck      string         `json:"deck"`
+	Deadline  time.Time      `json:"deadline"`
+	Remaining float64        `json:"remainingSeconds"`
+	Players   []playerView   `json:"players"`
+	Votes     map[string]int `json:"votes,omitempty"`
+	Log       []logEntry     `json:"log,omitempty"`
+}
+
+// roomSummary is a room as listed by the API.
+type roomSummary struct {
+	ID      string `json:"id"`
+	Players int    `json:"players"`
+	State   string `json:"state"`
+	Deck    string `json:"deck"`
+}
+
+type playerView struct {
+	Username string `json:"username"`
+	Alive    bool   `json:"alive"`
+	Presence string `json:"presence"`
+}
+
+// logEntry is a message the room announced to all its players.
+type logEntry struct {
+	Time time.Time `json:"time"`
+	Text string    `json:"text"`
+}
+
+// Answers a query of the API about the room.
+func (r *room) view(query roomQuery) roomView {
+	v := roomView{
+		ID:        r.id,
+		State:     r.engine.State().String(),
+		Deck:      r.deck,
+		Deadline:  r.engine.Deadline(),
+		Remaining: max(r.engine.Deadline().Sub(r.clock.Now()), 0).Seconds(),
+		Players:   []playerView{},
+		Log:       slices.Clone(r.journal),
+	}
+	for _, p := range r.playerList().Players {
+		v.Players = append(v.Players, playerView{Username: p.Username, Alive: p.Alive, Presence: p.Presence})
+	}
+	if query.admin {
+		v.Votes = r.engine.Votes()
+	}
+
+	return v
+}
+
+// Adds an entry to the log of the room, dropping the oldest one when full.
+func (r *room) record(text string) {
+	if len(r.journal) == log_limit {
+		r.journal = r.journal[1:]
+	}
+	r.journal = append(r.journal, logEntry{Time: r.clock.Now(), Text: text})
+}
+
+/*
+ * api answers HTTP queries about the rooms of the lobby. Requests bearing
+ * the admin token see the votes of the game.
+ */
+type api struct {
+	engine *actor.Engine
+	lobby  *actor.PID
+	token  string
+}
+
+// Errors the API answers with.
+var (
+	errUnauthorized = errors.New("invalid admin token")
+	errNotFound     = errors.New("no such room")
+)
+
+func (a *api) register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/rooms", a.rooms)
+	mux.HandleFunc("GET /api/rooms/{id}", a.room)
+	mux.HandleFunc("GET /api/rooms/{id}/log", a.log)
+}
+
+// Lists the rooms of the lobby.
+func (a *api) rooms(w http.ResponseWriter, req *http.Request) {
+	res, err := a.engine.Request(a.lobby, &types.ListRooms{}, api_timeout).Result()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	rooms := []roomSummary{}
+	for _, room := range res.(*types.RoomList).Rooms {
+		rooms = append(rooms, roomSummary{ID: room.Id, Players: int(room.Players), State: room.State, Deck: room.Deck})
+	}
+	writeJSON(w, http.StatusOK, rooms)
+}
+
+// Tells how the game of a room stands, without its log.
+func (a *api) room(w http.ResponseWriter, req *http.Request) {
+	v, err := a.query(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	v.Log = nil
+	writeJSON(w, http.StatusOK, v)
+}
+
+// Returns the messages a room announced to its players.
+func (a *api) log(w http.ResponseWriter, req *http.Request) {
+	v, err := a.query(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, append([]logEntry{}, v.Log...))
+}
+
+// Asks the room named in the request path for its view.
+func (a *api) query(req *http.Request) (roomView, error) {
+	admin, err := a.admin(req)
+	if err != nil {
+		return roomView{}, err
+	}
+
+	res, err := a.engine.Request(a.lobby, roomQuery{id: req.PathValue("id"), admin: admin}, api_timeout).Result()
+	if err != nil {
+		return roomView{}, err
+	}
+	v, ok := res.(roomView)
+	if !ok {
+		return roomView{}, errNotFound
+	}
+
+	return v, nil
+}
+
+/*
+ * Tells whether the request comes from an admin. A request bearing a token
+ * that is not the admin token is refused, one bearing none is not.
+ */
+func (a *api) admin(req *http.Request) (bool, error) {
+	header := req.Header.Get("Authorization")
+	if header == "" {
+		return false, nil
+	}
+
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || a.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+		return false, errUnauthorized
+	}
+
+	return true, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// Answers with the status matching the error.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, errUnauthorized):
+		status = http.StatusUnauthorized
+	case errors.Is(err, errNotFound):
+		status = http.StatusNotFound
+	}
+
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"werewolves-go/game"
+)
+
+// Gets the given path of the API, checks the status and decodes the body into v.
+func getJSON(t *testing.T, srv *httptest.Server, path string, token string, status int, v any) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != status {
+		t.Fatalf("GET %v = %v, want %d", path, resp.Status, status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("GET %v: %v", path, err)
+	}
+}
+
+func TestAPI(t *testing.T) {
+	h := newHarness(t)
+	srv := httptest.NewServer(newMux(h.engine, h.server, "secret"))
+	defer srv.Close()
+
+	roles := h.assignRoles(h.join("main", "a", "b", "c", "d"))
+	h.expectAll("Werewolves, open your eyes.")
+	h.next()
+	wolf, seer := roles[game.Werewolf][0], roles[game.Seer][0]
+	h.expect(wolf, "Choose the player to kill")
+	h.vote(wolf, seer.name)
+
+	var rooms []roomSummary
+	getJSON(t, srv, "/api/rooms", "", http.StatusOK, &rooms)
+	if len(rooms) != 1 || rooms[0].ID != "main" || rooms[0].Players != 4 || rooms[0].State != "werewolfvote" {
+		t.Errorf("rooms = %+v", rooms)
+	}
+
+	var v roomView
+	getJSON(t, srv, "/api/rooms/main", "", http.StatusOK, &v)
+	if v.State != "werewolfvote" || v.Remaining <= 0 || len(v.Players) != 4 || v.Votes != nil {
+		t.Errorf("room = %+v", v)
+	}
+	if p := v.Players[0]; p.Username != "a" || !p.Alive || p.Presence != "online" {
+		t.Errorf("first player = %+v", p)
+	}
+
+	// Only admins see the votes.
+	getJSON(t, srv, "/api/rooms/main", "secret", http.StatusOK, &v)
+	if v.Votes[seer.name] != 1 {
+		t.Errorf("votes = %v, want one against %v", v.Votes, seer.name)
+	}
+
+	var log []logEntry
+	getJSON(t, srv, "/api/rooms/main/log", "", http.StatusOK, &log)
+	if len(log) == 0 || log[0].Text != "a connected" || !slices.ContainsFunc(log, func(e logEntry) bool { return e.Text == "Werewolves, open your eyes." }) {
+		t.Errorf("log = %+v", log)
+	}
+
+	var failure map[string]string
+	getJSON(t, srv, "/api/rooms/main", "wrong", http.StatusUnauthorized, &failure)
+	getJSON(t, srv, "/api/rooms/nowhere", "", http.StatusNotFound, &failure)
+	if failure["error"] != "no such room" {
+		t.Errorf("error = %v", failure)
+	}
+}
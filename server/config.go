@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"werewolves-go/game"
+	"werewolves-go/network"
+)
+
+// Prefix of the environment variables the server reads its settings from.
+const env_prefix string = "WEREWOLVES_"
+
+/*
+ * config holds every setting of the server: where it listens, how the
+ * lobby hands out rooms and how games are played.
+ */
+type config struct {
+	Listen      string
+	Advertise   string
+	HTTP        string
+	AdminToken  string
+	DefaultRoom string
+	MaxRooms    int
+	Deck        string
+	Heartbeat   heartbeat
+	Game        game.Config
+}
+
+/*
+ * heartbeat says how often rooms check on their players and how long a
+ * player may stay silent before being taken for offline. Rooms do not check
+ * on their players when Interval is zero.
+ */
+type heartbeat struct {
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// Returns the settings the server runs with when nothing is configured.
+func defaultConfig() config {
+	return config{
+		Listen:      "127.0.0.1:4000",
+		HTTP:        "127.0.0.1:8080",
+		DefaultRoom: "main",
+		Heartbeat:   heartbeat{Interval: 5 * time.Second, Timeout: 30 * time.Second},
+		Game:        game.DefaultConfig(),
+	}
+}
+
+/*
+ * Registers a flag for every setting. The same names, upper cased with
+ * dashes turned into underscores, are used by the environment variables
+ * and, with underscores, by the config file.
+ */
+func (c *config) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.Listen, "listen", c.Listen, "host:port to open a receiver endpoint on, a bare port listens on 127.0.0.1")
+	fs.StringVar(&c.Advertise, "advertise", c.Advertise, "host:port clients reach the server at, when it differs from the listen address")
+	fs.StringVar(&c.HTTP, "http", c.HTTP, "host:port to serve browsers on, a bare port listens on 127.0.0.1, empty to not serve them")
+	fs.StringVar(&c.AdminToken, "admin-token", c.AdminToken, "token admins of the HTTP API authenticate with, empty to have no admins")
+	fs.StringVar(&c.DefaultRoom, "default-room", c.DefaultRoom, "room joined by clients that do not name one")
+	fs.IntVar(&c.MaxRooms, "max-rooms", c.MaxRooms, "maximum number of rooms, 0 for no limit")
+	fs.StringVar(&c.Deck, "deck", c.Deck, "roles dealt in every room, e.g. \"2 werewolves, 1 witch, rest villager\"; balanced presets when empty")
+	fs.DurationVar(&c.Heartbeat.Interval, "heartbeat-interval", c.Heartbeat.Interval, "time between two heartbeats sent to the players, 0 to never check on them")
+	fs.DurationVar(&c.Heartbeat.Timeout, "heartbeat-timeout", c.Heartbeat.Timeout, "time after which a silent player is taken for offline")
+
+	g := &c.Game
+	fs.IntVar(&g.MinPlayers, "min-players", g.MinPlayers, "players needed before a game starts")
+	fs.IntVar(&g.HealPotions, "heal-potions", g.HealPotions, "healing potions of the witch")
+	fs.IntVar(&g.PoisonPotions, "poison-potions", g.PoisonPotions, "poison potions of the witch")
+	fs.DurationVar(&g.ConnectionDuration, "connection-duration", g.ConnectionDuration, "time players have to join a game")
+	fs.DurationVar(&g.WerewolfDiscussion, "werewolf-discussion", g.WerewolfDiscussion, "time werewolves have to discuss")
+	fs.DurationVar(&g.TownspersonDiscussion, "townsperson-discussion", g.TownspersonDiscussion, "time the town has to discuss")
+	fs.DurationVar(&g.VotingDuration, "voting-duration", g.VotingDuration, "time werewolves and the town have to vote")
+	fs.DurationVar(&g.SeerDuration, "seer-duration", g.SeerDuration, "time the seer has to inspect a player")
+	fs.DurationVar(&g.HunterDuration, "hunter-duration", g.HunterDuration, "time a dying hunter has to shoot")
+	fs.DurationVar(&g.CupidDuration, "cupid-duration", g.CupidDuration, "time cupid has to pick the lovers")
+	fs.DurationVar(&g.BodyguardDuration, "bodyguard-duration", g.BodyguardDuration, "time the bodyguard has to protect a player")
+	fs.DurationVar(&g.WitchHealDuration, "witch-heal-duration", g.WitchHealDuration, "time the witch has to use her potions")
+	fs.DurationVar(&g.RematchDuration, "rematch-duration", g.RematchDuration, "time players have to ask for a rematch")
+	fs.StringVar(&g.Offline, "offline", g.Offline, "what games do about offline players: wait for them, skip their turns or have them abstain from votes")
+}
+
+/*
+ * Builds the configuration from, in increasing order of precedence, the
+ * defaults, the JSON file given with -config, the environment and the
+ * command line flags. The result is validated.
+ */
+func loadConfig(args []string, getenv func(string) string) (config, error) {
+	cfg := defaultConfig()
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	path := fs.String("config", getenv(env_prefix+"CONFIG"), "path of a JSON config file")
+	cfg.register(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return config{}, err
+	}
+
+	// Remember the flags given on the command line, they are applied last.
+	explicit := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = f.Value.String() })
+
+	if *path != "" {
+		if err := applyFile(fs, *path); err != nil {
+			return config{}, err
+		}
+	}
+
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		name := env_prefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if value := getenv(name); value != "" && f.Name != "config" {
+			if err := f.Value.Set(value); err != nil {
+				errs = append(errs, fmt.Errorf("%v: %w", name, err))
+			}
+		}
+	})
+	for name, value := range explicit {
+		if err := fs.Set(name, value); err != nil {
+			errs = append(errs, fmt.Errorf("-%v: %w", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return config{}, errors.Join(errs...)
+	}
+
+	return cfg, cfg.validate()
+}
+
+/*
+ * Sets the flags named by the keys of a JSON object read from path.
+ * Durations are written as strings such as "90s".
+ */
+func applyFile(fs *flag.FlagSet, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var settings map[string]any
+	if err := json.Unmarshal(content, &settings); err != nil {
+		return fmt.Errorf("%v: %w", path, err)
+	}
+
+	var errs []error
+	for key, value := range settings {
+		name := strings.ReplaceAll(key, "_", "-")
+		if fs.Lookup(name) == nil || name == "config" {
+			errs = append(errs, fmt.Errorf("%v: unknown setting %q", path, key))
+			continue
+		}
+
+		var text string
+		switch value := value.(type) {
+		case string:
+			text = value
+		case float64:
+			text = strconv.FormatFloat(value, 'f', -1, 64)
+		default:
+			errs = append(errs, fmt.Errorf("%v: setting %q must be a string or a number", path, key))
+			continue
+		}
+
+		if err := fs.Set(name, text); err != nil {
+			errs = append(errs, fmt.Errorf("%v: %v: %w", path, key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+/*
+ * Checks that the server can run with the configuration. Addresses are
+ * completed and the deck is parsed into the game configuration along the
+ * way.
+ */
+func (c *config) validate() error {
+	var errs []error
+
+	if listen, err := network.ListenAddress(c.Listen, "127.0.0.1"); err != nil {
+		errs = append(errs, fmt.Errorf("listen: %w", err))
+	} else if advertise, err := network.AdvertiseAddress(listen, c.Advertise); err != nil {
+		errs = append(errs, fmt.Errorf("advertise: %w", err))
+	} else {
+		c.Listen, c.Advertise = listen, advertise
+	}
+	if c.HTTP != "" {
+		if address, err := network.ListenAddress(c.HTTP, "127.0.0.1"); err != nil {
+			errs = append(errs, fmt.Errorf("http: %w", err))
+		} else {
+			c.HTTP = address
+		}
+	}
+	if c.DefaultRoom == "" || strings.ContainsAny(c.DefaultRoom, "/ ") {
+		errs = append(errs, fmt.Errorf("default-room: %q is not a valid room id", c.DefaultRoom))
+	}
+	if c.MaxRooms < 0 {
+		errs = append(errs, errors.New("max-rooms: must not be negative"))
+	}
+	if c.Heartbeat.Interval < 0 {
+		errs = append(errs, errors.New("heartbeat-interval: must not be negative"))
+	} else if c.Heartbeat.Interval > 0 && c.Heartbeat.Timeout <= missed_heartbeats*c.Heartbeat.Interval {
+		errs = append(errs, fmt.Errorf("heartbeat-timeout: must be longer than %d heartbeat intervals", missed_heartbeats))
+	}
+
+	g := &c.Game
+	if g.MinPlayers < 2 {
+		errs = append(errs, fmt.Errorf("min-players: a game needs at least 2 players, not %d", g.MinPlayers))
+	}
+	if g.HealPotions < 0 || g.PoisonPotions < 0 {
+		errs = append(errs, errors.New("heal-potions, poison-potions: must not be negative"))
+	}
+	if !slices.Contains(game.OfflinePolicies(), g.Offline) {
+		errs = append(errs, fmt.Errorf("offline: %q is not one of %v", g.Offline, strings.Join(game.OfflinePolicies(), ", ")))
+	}
+	for _, setting := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"connection-duration", g.ConnectionDuration},
+		{"werewolf-discussion", g.WerewolfDiscussion},
+		{"townsperson-discussion", g.TownspersonDiscussion},
+		{"voting-duration", g.VotingDuration},
+		{"seer-duration", g.SeerDuration},
+		{"hunter-duration", g.HunterDuration},
+		{"cupid-duration", g.CupidDuration},
+		{"bodyguard-duration", g.BodyguardDuration},
+		{"witch-heal-duration", g.WitchHealDuration},
+		{"rematch-duration", g.RematchDuration},
+	} {
+		if setting.d <= 0 {
+			errs = append(errs, fmt.Errorf("%v: %v is not a positive duration", setting.name, setting.d))
+		}
+	}
+
+	g.Deck = nil
+	if c.Deck != "" {
+		deck, err := game.ParseDeck(c.Deck)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("deck: %w", err))
+		} else {
+			g.Deck = &deck
+		}
+	} else if _, err := game.PresetFor(g.Presets, g.MinPlayers); err != nil {
+		errs = append(errs, fmt.Errorf("min-players: %w Raise it or set a deck.", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Logs every setting the server runs with.
+func (c config) log(logger *slog.Logger) {
+	g := c.Game
+	logger.Info("effective configuration",
+		"listen", c.Listen,
+		"advertise", c.Advertise,
+		"http", c.HTTP,
+		"admin-token", c.AdminToken != "",
+		"default-room", c.DefaultRoom,
+		"max-rooms", c.MaxRooms,
+		"deck", c.Deck,
+		"heartbeat-interval", c.Heartbeat.Interval,
+		"heartbeat-timeout", c.Heartbeat.Timeout,
+		"min-players", g.MinPlayers,
+		"heal-potions", g.HealPotions,
+		"poison-potions", g.PoisonPotions,
+		"connection-duration", g.ConnectionDuration,
+		"werewolf-discussion", g.WerewolfDiscussion,
+		"townsperson-discussion", g.TownspersonDiscussion,
+		"voting-duration", g.VotingDuration,
+		"seer-duration", g.SeerDuration,
+		"hunter-duration", g.HunterDuration,
+		"cupid-duration", g.CupidDuration,
+		"bodyguard-duration", g.BodyguardDuration,
+		"witch-heal-duration", g.WitchHealDuration,
+		"rematch-duration", g.RematchDuration,
+		"offline", g.Offline,
+	)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Returns a getenv function reading from the given variables only.
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.json")
+	content := `{"voting_duration": "90s", "min_players": 6, "seer_duration": "10s", "max_rooms": 3}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfig(
+		[]string{"-config", path, "-voting-duration", "2m"},
+		env(map[string]string{"WEREWOLVES_SEER_DURATION": "20s", "WEREWOLVES_DECK": "1 werewolf, rest villager"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Game.VotingDuration != 2*time.Minute {
+		t.Errorf("flag should win over the file, voting duration is %v", cfg.Game.VotingDuration)
+	}
+	if cfg.Game.SeerDuration != 20*time.Second {
+		t.Errorf("environment should win over the file, seer duration is %v", cfg.Game.SeerDuration)
+	}
+	if cfg.Game.MinPlayers != 6 || cfg.MaxRooms != 3 {
+		t.Errorf("file settings were not applied: %+v", cfg)
+	}
+	if cfg.Game.Deck == nil || cfg.Game.Deck.String() != "1 werewolf, rest townsperson" {
+		t.Errorf("deck was not parsed: %v", cfg.Game.Deck)
+	}
+	if cfg.Game.WitchHealDuration != 30*time.Second {
+		t.Errorf("unset settings should keep their default, witch heal duration is %v", cfg.Game.WitchHealDuration)
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.json")
+	if err := os.WriteFile(path, []byte(`{"voting_time": "1m"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig([]string{"-config", path}, env(nil)); err == nil || !strings.Contains(err.Error(), `unknown setting "voting_time"`) {
+		t.Errorf("unknown file setting was accepted: %v", err)
+	}
+
+	_, err := loadConfig(
+		[]string{"-listen", "http", "-seer-duration", "0s", "-min-players", "3", "-heartbeat-timeout", "5s", "-offline", "kick"},
+		env(map[string]string{"WEREWOLVES_DECK": "1 wizard"}),
+	)
+	if err == nil {
+		t.Fatal("invalid configuration was accepted")
+	}
+	for _, want := range []string{"listen:", "seer-duration:", `deck: Unknown role "wizard"`, "heartbeat-timeout:", `offline: "kick"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+
+	if _, err := loadConfig([]string{"-min-players", "3"}, env(nil)); err == nil || !strings.Contains(err.Error(), "No role preset is meant for 3 players") {
+		t.Errorf("missing preset was accepted: %v", err)
+	}
+}
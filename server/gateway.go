@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"werewolves-go/types"
+
+	"github.com/anthdm/hollywood/actor"
+	"golang.org/x/net/websocket"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+/*
+ * envelope is how a protocol message travels over a WebSocket: the name of
+ * its type, e.g. "CastVote", and the message itself in protobuf's JSON
+ * mapping. 64 bit integers such as deadlines are written as strings.
+ */
+type envelope struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// Messages a browser may send to the server, by name.
+var inbound = messageTypes(
+	&types.Connect{}, &types.Reconnect{}, &types.Disconnect{}, &types.Heartbeat{},
+	&types.Message{}, &types.ListRooms{}, &types.CreateRoom{}, &types.JoinRoom{}, &types.LeaveRoom{},
+	&types.CastVote{}, &types.UseHeal{}, &types.UsePoison{}, &types.InspectPlayer{},
+	&types.ProtectPlayer{}, &types.ShootPlayer{}, &types.LinkLovers{}, &types.RequestRematch{},
+)
+
+func messageTypes(msgs ...proto.Message) map[string]protoreflect.MessageType {
+	byName := make(map[string]protoreflect.MessageType)
+	for _, msg := range msgs {
+		byName[string(msg.ProtoReflect().Descriptor().Name())] = msg.ProtoReflect().Type()
+	}
+
+	return byName
+}
+
+// Wraps a protocol message into the JSON text sent over a WebSocket.
+func encodeEnvelope(msg proto.Message) ([]byte, error) {
+	data, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(envelope{Type: string(msg.ProtoReflect().Descriptor().Name()), Data: data})
+}
+
+// Unwraps the protocol message a browser sent over a WebSocket.
+func decodeEnvelope(text []byte) (proto.Message, error) {
+	var env envelope
+	if err := json.Unmarshal(text, &env); err != nil {
+		return nil, fmt.Errorf("Messages must be JSON objects with a type and data: %v", err)
+	}
+
+	mt, ok := inbound[env.Type]
+	if !ok {
+		return nil, fmt.Errorf("Unknown message type %q.", env.Type)
+	}
+	msg := mt.New().Interface()
+	if len(env.Data) > 0 {
+		if err := protojson.Unmarshal(env.Data, msg); err != nil {
+			return nil, fmt.Errorf("Invalid %v: %v", env.Type, err)
+		}
+	}
+
+	return msg, nil
+}
+
+/*
+ * socket is the actor standing for a browser connected over a WebSocket.
+ * The lobby and the rooms talk to it like to any other client, and it
+ * writes whatever they send to the socket.
+ */
+type socket struct {
+	conn   *websocket.Conn
+	logger *slog.Logger
+}
+
+func (s *socket) Receive(ctx *actor.Context) {
+	msg, ok := ctx.Message().(proto.Message)
+	if !ok {
+		return
+	}
+
+	text, err := encodeEnvelope(msg)
+	if err == nil {
+		err = websocket.Message.Send(s.conn, string(text))
+	}
+	if err != nil {
+		s.logger.Warn("message not delivered to websocket", "err", err)
+	}
+}
+
+/*
+ * gateway lets browsers play over WebSockets. Every socket gets an actor of
+ * its own, whose messages are sent to the lobby.
+ */
+type gateway struct {
+	engine  *actor.Engine
+	lobby   *actor.PID
+	sockets atomic.Uint64
+	logger  *slog.Logger
+}
+
+// Returns the handler accepting WebSockets on behalf of the lobby.
+func newGateway(engine *actor.Engine, lobby *actor.PID) http.Handler {
+	g := &gateway{engine: engine, lobby: lobby, logger: slog.Default().With("gateway", "websocket")}
+
+	// Browsers from any origin may play, no cookie is ever looked at.
+	return websocket.Server{Handler: g.serve}
+}
+
+/*
+ * Relays the messages read from a socket to the lobby until the browser
+ * goes away, which counts as a disconnection.
+ */
+func (g *gateway) serve(conn *websocket.Conn) {
+	id := fmt.Sprint(g.sockets.Add(1))
+	pid := g.engine.Spawn(func() actor.Receiver {
+		return &socket{conn: conn, logger: g.logger.With("socket", id)}
+	}, "socket", actor.WithID(id))
+	g.logger.Info("websocket connected", "socket", id, "addr", conn.Request().RemoteAddr)
+
+	defer func() {
+		g.engine.SendWithSender(g.lobby, &types.Disconnect{}, pid)
+		g.engine.Poison(pid).Wait()
+		conn.Close()
+		g.logger.Info("websocket disconnected", "socket", id)
+	}()
+
+	for {
+		var text []byte
+		if err := websocket.Message.Receive(conn, &text); err != nil {
+			return
+		}
+
+		msg, err := decodeEnvelope(text)
+		if err != nil {
+			g.engine.Send(pid, &types.ErrorReply{Msg: err.Error()})
+			continue
+		}
+		if _, ok := msg.(*types.Disconnect); ok {
+			return
+		}
+		g.engine.SendWithSender(g.lobby, msg, pid)
+	}
+}
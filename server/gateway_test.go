@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"werewolves-go/types"
+
+	"golang.org/x/net/websocket"
+)
+
+// Dials the gateway of the given test server like a browser would.
+func dialGateway(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	t.Helper()
+
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// Sends a JSON message of the given type over the socket.
+func sendJSON(t *testing.T, conn *websocket.Conn, typ string, data string) {
+	t.Helper()
+
+	if err := websocket.Message.Send(conn, `{"type": "`+typ+`", "data": `+data+`}`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Reads from the socket until a message of the given type containing text arrives.
+func expectJSON(t *testing.T, conn *websocket.Conn, typ string, text string) {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var env envelope
+		if err := websocket.JSON.Receive(conn, &env); err != nil {
+			t.Fatalf("never received a %v containing %q: %v", typ, text, err)
+		}
+		if env.Type == typ && strings.Contains(string(env.Data), text) {
+			return
+		}
+	}
+}
+
+func TestGateway(t *testing.T) {
+	h := newHarness(t)
+	srv := httptest.NewServer(newMux(h.engine, h.server, ""))
+	defer srv.Close()
+
+	web := dialGateway(t, srv)
+	sendJSON(t, web, "Connect", `{"username": "web", "room": "main"}`)
+	expectJSON(t, web, "PlayerList", `"username":"web"`)
+	expectJSON(t, web, "Session", `"room":"main"`)
+
+	// Browsers and CLI clients play in the same room.
+	cli := h.join("main", "cli")[0]
+	expectJSON(t, web, "Message", "cli connected")
+	sendJSON(t, web, "Message", `{"msg": "hello from the browser"}`)
+	h.expect(cli, "web: hello from the browser")
+	h.say(cli, "hello from the terminal")
+	expectJSON(t, web, "Message", `"username":"cli","msg":"hello from the terminal"`)
+
+	sendJSON(t, web, "ListRooms", `{}`)
+	expectJSON(t, web, "RoomList", `"players":2`)
+	sendJSON(t, web, "GameOver", `{}`)
+	expectJSON(t, web, "ErrorReply", `Unknown message type \"GameOver\"`)
+
+	// Closing the socket disconnects the player.
+	web.Close()
+	h.expect(cli, "web left the room.")
+}
+
+func TestWebClient(t *testing.T) {
+	h := newHarness(t)
+	srv := httptest.NewServer(newMux(h.engine, h.server, ""))
+	defer srv.Close()
+
+	for path, text := range map[string]string{"/": "<title>Werewolves</title>", "/app.js": "WebSocket", "/style.css": "#player-list"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), text) {
+			t.Errorf("GET %v = %v, want %q in the body", path, resp.Status, text)
+		}
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	text, err := encodeEnvelope(&types.PhaseChanged{Phase: "townvote", Deadline: 1700000000000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var env envelope
+	if err := json.Unmarshal(text, &env); err != nil {
+		t.Fatal(err)
+	}
+	if env.Type != "PhaseChanged" || string(env.Data) != `{"phase":"townvote","deadline":"1700000000000"}` {
+		t.Errorf("encoded %s", text)
+	}
+
+	msg, err := decodeEnvelope([]byte(`{"type": "LinkLovers", "data": {"first": "a", "second": "b"}}`))
+	if link, ok := msg.(*types.LinkLovers); err != nil || !ok || link.First != "a" || link.Second != "b" {
+		t.Errorf("decoded %v, %v", msg, err)
+	}
+	if _, err := decodeEnvelope([]byte(`{"type": "Connect", "data": {"name": "a"}}`)); err == nil {
+		t.Error("unknown field was accepted")
+	}
+}
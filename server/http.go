@@ -0,0 +1,34 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// The browser client, served at the root of the HTTP endpoint.
+//
+//go:embed web
+var webFiles embed.FS
+
+/*
+ * Returns the handler of everything the server serves over HTTP on behalf
+ * of the given lobby. Requests to the API bearing the given admin token
+ * are answered as coming from an admin.
+ */
+func newMux(engine *actor.Engine, lobby *actor.PID, adminToken string) *http.ServeMux {
+	web, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		panic(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/ws", newGateway(engine, lobby))
+	(&api{engine: engine, lobby: lobby, token: adminToken}).register(mux)
+	mux.HandleFunc("GET /api/events", spectate(engine))
+	mux.Handle("/", http.FileServerFS(web))
+
+	return mux
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"werewolves-go/game"
+	"werewolves-go/server/utils"
+	"werewolves-go/types"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+/*
+ * session is what a session token stands for: the seat of a player in a
+ * room and the client currently holding it.
+ */
+type session struct {
+	room     string
+	username string
+	client   string
+}
+
+/*
+ * Lobby structure that keeps track of the rooms hosted by the server, of
+ * the room every client is playing in and of the sessions of the players.
+ */
+type lobby struct {
+	cfg      config
+	clock    game.Clock
+	rooms    map[string]*actor.PID
+	status   map[string]roomStatus
+	clients  clientMap
+	users    map[string]string
+	members  map[string]string
+	sessions map[string]*session
+	logger   *slog.Logger
+}
+
+/*
+ * Instantiate the lobby actor. Rooms it creates play with the given
+ * configuration and measure their deadlines on the given clock.
+ */
+func newLobby(cfg config, clock game.Clock) actor.Producer {
+	return func() actor.Receiver {
+		return &lobby{
+			cfg:      cfg,
+			clock:    clock,
+			rooms:    make(map[string]*actor.PID),
+			status:   make(map[string]roomStatus),
+			clients:  make(clientMap),
+			users:    make(map[string]string),
+			members:  make(map[string]string),
+			sessions: make(map[string]*session),
+			logger:   slog.Default(),
+		}
+	}
+}
+
+/*
+ * Receive messages from clients and rooms. Client messages that belong to a
+ * game are routed to the room the client is in.
+ */
+func (l *lobby) Receive(ctx *actor.Context) {
+	switch msg := ctx.Message().(type) {
+	case actor.Stopped:
+		for _, pid := range l.rooms {
+			ctx.Engine().Poison(pid).Wait()
+		}
+		l.logger.Info("Lobby closed.")
+	case roomStatus:
+		l.updateStatus(ctx, msg)
+	case roomLeft:
+		if l.members[msg.client] == msg.id {
+			delete(l.members, msg.client)
+			if msg.gone {
+				delete(l.clients, msg.client)
+				delete(l.users, msg.client)
+			}
+		}
+		delete(l.sessions, msg.token)
+	case *types.Connect:
+		// A client keeps the name it first connected with.
+		cID := ctx.Sender().String()
+		if username, ok := l.users[cID]; ok && username != msg.Username {
+			l.logger.Warn("spoofed username rejected", "from", cID, "username", username, "claimed", msg.Username)
+			ctx.Send(ctx.Sender(), &types.ErrorReply{Msg: fmt.Sprintf("You are already connected as %v.", username)})
+			return
+		} else if !ok {
+			if err := l.checkUsername(cID, msg.Username); err != nil {
+				l.logger.Info("username rejected", "from", cID, "username", msg.Username, "err", err)
+				ctx.Send(ctx.Sender(), &types.UsernameRejected{Username: msg.Username, Reason: err.Error()})
+				return
+			}
+		}
+		l.clients[cID] = ctx.Sender()
+		l.users[cID] = msg.Username
+		if l.inRoom(ctx) {
+			return
+		}
+
+		roomID := msg.Room
+		if roomID == "" {
+			roomID = l.cfg.DefaultRoom
+		}
+		if _, ok := l.rooms[roomID]; !ok {
+			if err := l.createRoom(ctx, roomID, ""); err != nil {
+				l.reply(ctx, err.Error())
+				return
+			}
+		}
+		l.joinRoom(ctx, roomID)
+	case *types.ListRooms:
+		ctx.Send(ctx.Sender(), l.roomList())
+	case roomQuery:
+		// The room answers the API directly, nil means there is no such room.
+		if pid, ok := l.rooms[msg.id]; ok {
+			ctx.Engine().SendWithSender(pid, msg, ctx.Sender())
+		} else {
+			ctx.Respond(nil)
+		}
+	case *types.CreateRoom:
+		// A room nobody could enter would only wait for players forever.
+		if l.inRoom(ctx) {
+			return
+		}
+		if err := l.createRoom(ctx, msg.Room, msg.Deck); err != nil {
+			l.reply(ctx, err.Error())
+			return
+		}
+		l.joinRoom(ctx, msg.Room)
+	case *types.JoinRoom:
+		if _, ok := l.rooms[msg.Room]; !ok {
+			l.reply(ctx, fmt.Sprintf("Room %v does not exist. Type /create %v to create it.", msg.Room, msg.Room))
+			return
+		}
+		l.joinRoom(ctx, msg.Room)
+	case *types.LeaveRoom:
+		if _, ok := l.members[ctx.Sender().String()]; !ok {
+			l.reply(ctx, "You are not in a room.")
+			return
+		}
+		l.route(ctx)
+	case *types.Reconnect:
+		l.reconnect(ctx, msg.Token)
+	case *types.Heartbeat:
+		// Only players are checked on, stray heartbeats need no answer.
+		if _, ok := l.members[ctx.Sender().String()]; ok {
+			l.route(ctx)
+		}
+	case *types.Message, *types.CastVote, *types.UseHeal, *types.UsePoison, *types.InspectPlayer,
+		*types.ProtectPlayer, *types.ShootPlayer, *types.LinkLovers, *types.RequestRematch:
+		l.route(ctx)
+	case *types.Disconnect:
+		cID := ctx.Sender().String()
+		l.route(ctx)
+		delete(l.members, cID)
+		delete(l.clients, cID)
+		delete(l.users, cID)
+	}
+}
+
+/*
+ * Checks that the given client may take the username: it must be valid and
+ * neither be used by another client nor kept for a player who lost their
+ * connection.
+ */
+func (l *lobby) checkUsername(cID string, name string) error {
+	if err := validateUsername(name); err != nil {
+		return err
+	}
+
+	for other, username := range l.users {
+		if other != cID && strings.EqualFold(username, name) {
+			return fmt.Errorf("%v is already taken.", name)
+		}
+	}
+	for _, s := range l.sessions {
+		if s.client != cID && strings.EqualFold(s.username, name) {
+			return fmt.Errorf("%v is already taken.", name)
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Spawns a room with the given id on behalf of the lobby. The room deals
+ * its games from the given deck specification, or from the preset meant
+ * for its number of players when it is empty. Rooms are not spawned as
+ * children because the engine never forgets a stopped child, which would
+ * block the lobby from shutting down once a room is closed.
+ */
+func (l *lobby) createRoom(ctx *actor.Context, roomID string, spec string) error {
+	if roomID == "" || strings.ContainsAny(roomID, "/ ") {
+		return fmt.Errorf("Room id %q is not valid.", roomID)
+	}
+	if _, ok := l.rooms[roomID]; ok {
+		return fmt.Errorf("Room %v already exists. Type /join %v to join it.", roomID, roomID)
+	}
+
+	if l.cfg.MaxRooms > 0 && len(l.rooms) >= l.cfg.MaxRooms {
+		return fmt.Errorf("The server cannot host more than %d rooms. Type /rooms to join one.", l.cfg.MaxRooms)
+	}
+
+	cfg := l.cfg.Game
+	if spec != "" {
+		deck, err := game.ParseDeck(spec)
+		if err != nil {
+			return fmt.Errorf("Room %v was not created: %w", roomID, err)
+		}
+		cfg.Deck = &deck
+		spec = deck.String()
+	}
+
+	l.rooms[roomID] = ctx.Engine().Spawn(newRoom(roomID, ctx.PID(), l.clock, cfg, l.cfg.Heartbeat), "room", actor.WithID(roomID))
+	l.status[roomID] = roomStatus{id: roomID, state: game.Connect, deck: spec}
+	l.logger.Info("room created", "room", roomID, "deck", spec, "by", ctx.Sender())
+
+	return nil
+}
+
+/*
+ * Moves the sender into the given room. The room decides whether the
+ * player may join and reports back through its status.
+ */
+func (l *lobby) joinRoom(ctx *actor.Context, roomID string) {
+	cID := ctx.Sender().String()
+	username, ok := l.users[cID]
+	if !ok {
+		l.reply(ctx, "Please connect before joining a room.")
+		return
+	}
+	if l.inRoom(ctx) {
+		return
+	}
+
+	token, err := newToken()
+	if err != nil {
+		l.logger.Error("session token not issued", "err", err)
+		l.reply(ctx, "The server could not let you in, please try again.")
+		return
+	}
+
+	l.members[cID] = roomID
+	l.sessions[token] = &session{room: roomID, username: username, client: cID}
+	ctx.Send(l.rooms[roomID], roomMessage{
+		sender: ctx.Sender(),
+		msg:    &types.Connect{Username: username, Room: roomID},
+		token:  token,
+	})
+}
+
+/*
+ * Tells the sender it has to leave the room it is in first, if it is in
+ * one.
+ */
+func (l *lobby) inRoom(ctx *actor.Context) bool {
+	current, ok := l.members[ctx.Sender().String()]
+	if ok {
+		l.reply(ctx, fmt.Sprintf("You are already in room %v. Type /leave to leave it once the game is over.", current))
+	}
+
+	return ok
+}
+
+/*
+ * Hands the seat of the session with the given token over to the sender,
+ * a client connecting again after it lost its connection. The connection
+ * the player had before is forgotten.
+ */
+func (l *lobby) reconnect(ctx *actor.Context, token string) {
+	cID := ctx.Sender().String()
+	s, ok := l.sessions[token]
+	if !ok {
+		ctx.Send(ctx.Sender(), &types.ErrorReply{Msg: "Your session has expired. Connect again to join a game."})
+		return
+	}
+	// A client that comes back from the same address takes its own seat again.
+	if current, ok := l.members[cID]; ok && s.client != cID {
+		l.reply(ctx, fmt.Sprintf("You are already in room %v.", current))
+		return
+	}
+
+	if s.client != cID {
+		if l.members[s.client] == s.room {
+			delete(l.members, s.client)
+		}
+		delete(l.clients, s.client)
+		delete(l.users, s.client)
+	}
+	s.client = cID
+	l.clients[cID] = ctx.Sender()
+	l.users[cID] = s.username
+	l.members[cID] = s.room
+	l.logger.Info("client reconnected", "room", s.room, "username", s.username, "client", cID)
+
+	ctx.Send(l.rooms[s.room], roomMessage{sender: ctx.Sender(), msg: ctx.Message()})
+}
+
+// Returns a new random session token.
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+/*
+ * Hands the current message over to the room of its sender.
+ */
+func (l *lobby) route(ctx *actor.Context) {
+	roomID, ok := l.members[ctx.Sender().String()]
+	if !ok {
+		l.reply(ctx, "You are not in a room. Type /rooms, /create <room> or /join <room>.")
+		return
+	}
+
+	ctx.Send(l.rooms[roomID], roomMessage{sender: ctx.Sender(), msg: ctx.Message()})
+}
+
+/*
+ * Records the status of a room. Rooms whose game has ended are cleaned up
+ * and their players go back to the lobby.
+ */
+func (l *lobby) updateStatus(ctx *actor.Context, status roomStatus) {
+	pid, ok := l.rooms[status.id]
+	if !ok {
+		return
+	}
+	l.status[status.id] = status
+
+	if status.state != game.Closed {
+		return
+	}
+
+	for cID, roomID := range l.members {
+		if roomID == status.id {
+			delete(l.members, cID)
+			ctx.Send(l.clients[cID], utils.FormatMessageResponseFromServer(
+				fmt.Sprintf("Room %v is closed. Type /rooms, /create <room> or /join <room> to play again.", status.id)))
+		}
+	}
+
+	for token, s := range l.sessions {
+		if s.room == status.id {
+			delete(l.sessions, token)
+		}
+	}
+
+	delete(l.rooms, status.id)
+	delete(l.status, status.id)
+	ctx.Engine().Poison(pid)
+	l.logger.Info("room closed", "room", status.id)
+}
+
+/*
+ * Returns the rooms hosted by the server sorted by id.
+ */
+func (l *lobby) roomList() *types.RoomList {
+	list := &types.RoomList{}
+	for roomID, status := range l.status {
+		list.Rooms = append(list.Rooms, &types.Room{
+			Id:      roomID,
+			Players: int32(status.players),
+			State:   status.state.String(),
+			Deck:    status.deck,
+		})
+	}
+	sort.Slice(list.Rooms, func(i, j int) bool { return list.Rooms[i].Id < list.Rooms[j].Id })
+
+	return list
+}
+
+// Replies to the sender of the current message.
+func (l *lobby) reply(ctx *actor.Context, message string) {
+	ctx.Send(ctx.Sender(), utils.FormatMessageResponseFromServer(message))
+}
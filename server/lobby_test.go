@@ -0,0 +1,172 @@
+package main
+
+import (
+	"maps"
+	"strings"
+	"testing"
+	"time"
+	"werewolves-go/game"
+	"werewolves-go/types"
+)
+
+// Waits for the next room list the client receives.
+func (h *harness) expectRooms(c *testClient) *types.RoomList {
+	h.t.Helper()
+
+	select {
+	case list := <-c.rooms:
+		return list
+	case <-time.After(2 * time.Second):
+		h.t.Fatalf("%v never received a room list", c.name)
+	}
+
+	return nil
+}
+
+func TestRoomsAreIndependent(t *testing.T) {
+	h := newHarness(t)
+	red := h.join("red", "a", "b")
+	blue := h.join("blue", "c")
+
+	// Chat stays inside the room it was typed in.
+	h.say(red[0], "hello red")
+	h.expect(red[1], "hello red")
+	h.say(red[1], "still red")
+	h.expect(red[0], "still red")
+	select {
+	case msg := <-blue[0].msgs:
+		t.Fatalf("blue room received %q", msg)
+	default:
+	}
+
+	h.send(blue[0], &types.ListRooms{})
+	list := h.expectRooms(blue[0])
+	if len(list.Rooms) != 2 {
+		t.Fatalf("expected 2 rooms, got %v", list.Rooms)
+	}
+	for i, want := range []struct {
+		id      string
+		players int32
+	}{{"blue", 1}, {"red", 2}} {
+		if list.Rooms[i].Id != want.id || list.Rooms[i].Players != want.players || list.Rooms[i].State != "connect" {
+			t.Errorf("room %d = %v, want %v with %v players", i, list.Rooms[i], want.id, want.players)
+		}
+	}
+}
+
+func TestCreateAndJoinRooms(t *testing.T) {
+	h := newHarness(t)
+	a := h.join("red", "a")[0]
+
+	// Players in a room neither create nor join another one.
+	h.send(a, &types.CreateRoom{Room: "green"})
+	h.expect(a, "You are already in room red. Type /leave to leave it")
+	h.send(a, &types.ListRooms{})
+	if list := h.expectRooms(a); len(list.Rooms) != 1 {
+		t.Errorf("rooms = %v, want red alone", list.Rooms)
+	}
+
+	h.send(a, &types.LeaveRoom{})
+	h.expect(a, "left room red")
+	h.send(a, &types.LeaveRoom{})
+	h.expect(a, "You are not in a room.")
+
+	h.send(a, &types.CreateRoom{Room: "red"})
+	h.expect(a, "Room red already exists")
+
+	h.send(a, &types.JoinRoom{Room: "green"})
+	h.expect(a, "Room green does not exist")
+
+	h.send(a, &types.CreateRoom{Room: "green"})
+	h.expect(a, "a connected")
+
+	b := h.join("blue", "b")[0]
+	h.send(b, &types.JoinRoom{Room: "red"})
+	h.expect(b, "You are already in room blue")
+}
+
+func TestCreateRoomWithDeck(t *testing.T) {
+	h := newHarness(t)
+	a := h.join("hall", "a")[0]
+	h.send(a, &types.LeaveRoom{})
+	h.expect(a, "left room hall")
+
+	h.send(a, &types.CreateRoom{Room: "bad", Deck: "2 werewolf, 1 wizard"})
+	h.expect(a, `Room bad was not created: Unknown role "wizard"`)
+	h.send(a, &types.CreateRoom{Room: "bad", Deck: "1 witch, rest villager"})
+	h.expect(a, "has no werewolf")
+	h.send(a, &types.CreateRoom{Room: "bad", Deck: "1 werewolf, rest werewolf"})
+	h.expect(a, "leaves no villager whatever the number of players")
+
+	h.send(a, &types.CreateRoom{Room: "small", Deck: "2 werewolves, 1 witch, 1 seer, 1 hunter, rest villager"})
+	h.expect(a, "a connected")
+	players := append([]*testClient{a}, h.join("small", "b", "c", "d")...)
+
+	// Both rooms close their connection window at the same time.
+	h.next()
+	h.next()
+	for _, c := range players {
+		h.expect(c, "needs at least 5 players but only 4 joined. Extending time....")
+	}
+
+	h.send(a, &types.ListRooms{})
+	list := h.expectRooms(a)
+	if deck := list.Rooms[1].Deck; deck != "2 werewolf, 1 witch, 1 seer, 1 hunter, rest townsperson" {
+		t.Errorf("room small has deck %q", deck)
+	}
+
+	players = append(players, h.join("small", "f")...)
+	h.next()
+	h.next()
+	roles := make(map[string]int)
+	for _, c := range players {
+		msg := h.expect(c, "You are a ")
+		roles[strings.Fields(strings.SplitAfter(msg, "You are a ")[1])[0]]++
+	}
+	want := map[string]int{game.Werewolf: 2, game.Witch: 1, game.Seer: 1, game.Hunter: 1}
+	if !maps.Equal(roles, want) {
+		t.Errorf("dealt %v, want %v", roles, want)
+	}
+}
+
+func TestUsernames(t *testing.T) {
+	h := newHarness(t)
+	h.join("main", "alice")
+	c := h.spawn("newcomer", "newcomer")
+
+	for name, reason := range map[string]string{
+		"ALICE":               "ALICE is already taken.",
+		"server/primary":      "server/primary is a reserved name.",
+		"pass":                "pass is a reserved name.",
+		"Werewolf":            "Werewolf is a reserved name.",
+		"":                    "Usernames are 1 to 16 characters long.",
+		"a-name-far-too-long": "Usernames are 1 to 16 characters long.",
+		"bob smith":           "Usernames may only contain letters",
+	} {
+		h.send(c, &types.Connect{Username: name, Room: "main"})
+		h.expect(c, "rejected: "+reason)
+	}
+
+	// A refused client may try again with another name.
+	h.send(c, &types.Connect{Username: "bob", Room: "main"})
+	h.expect(c, "bob connected")
+}
+
+func TestTimedOutNameIsFree(t *testing.T) {
+	cfg := testConfig()
+	cfg.Heartbeat = heartbeat{Interval: time.Second, Timeout: 3 * time.Second}
+	cfg.Game.ConnectionDuration = time.Minute
+	h := newHarnessWith(t, cfg)
+	players := h.join("main", "a", "b")
+
+	// a stops answering and leaves the room before the game starts.
+	players[0].muted.Store(true)
+	for i := 0; i < 5; i++ {
+		h.beat(time.Second, players[1])
+	}
+	h.expect(players[1], "a left the room.")
+
+	c := h.spawn("a", "a-again")
+	h.send(c, &types.Connect{Username: "a", Room: "main"})
+	h.expect(c, "a connected")
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"werewolves-go/game"
+)
+
+// Length limits of a username, in characters.
+const (
+	min_username_length = 1
+	max_username_length = 16
+)
+
+/*
+ * Names nobody may play under: the one the server signs its messages with,
+ * words the commands give a meaning to and the names of the roles.
+ */
+func reservedNames() []string {
+	return append([]string{"server", "primary", "server/primary", "moderator", "pass", "all", "everyone", "nobody"}, game.Roles()...)
+}
+
+/*
+ * Checks that a username can be played under. Usernames are made of
+ * letters, digits, dashes and underscores and are not case sensitive.
+ */
+func validateUsername(name string) error {
+	if length := len([]rune(name)); length < min_username_length || length > max_username_length {
+		return fmt.Errorf("Usernames are %d to %d characters long.", min_username_length, max_username_length)
+	}
+	if slices.ContainsFunc(reservedNames(), func(reserved string) bool { return strings.EqualFold(reserved, name) }) {
+		return fmt.Errorf("%v is a reserved name.", name)
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return errors.New("Usernames may only contain letters, digits, dashes and underscores.")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"time"
+	"werewolves-go/game"
+	"werewolves-go/types"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// Number of heartbeats a player may miss before being taken for away.
+const missed_heartbeats = 2
+
+/*
+ * presence tells whether a player is still there. Away players missed a
+ * few heartbeats, offline players are gone until they reconnect.
+ */
+type presence int
+
+const (
+	online presence = iota
+	away
+	offline
+)
+
+func (p presence) String() string {
+	switch p {
+	case online:
+		return "online"
+	case away:
+		return "away"
+	case offline:
+		return "offline"
+	}
+
+	return "unknown"
+}
+
+/*
+ * presenceCheck is delivered to a room every heartbeat interval.
+ */
+type presenceCheck struct{}
+
+/*
+ * Arranges for the next presence check, unless the room does not check on
+ * its players.
+ */
+func (r *room) schedulePulse(ctx *actor.Context) {
+	if r.beat.Interval <= 0 || r.engine.State() == game.Closed {
+		return
+	}
+
+	engine, pid := ctx.Engine(), ctx.PID()
+	r.pulse = r.clock.AfterFunc(r.beat.Interval, func() {
+		engine.Send(pid, presenceCheck{})
+	})
+}
+
+/*
+ * Updates the presence of every connected player from the number of
+ * heartbeats they left unanswered, and sends them the next one.
+ */
+func (r *room) checkPresence(ctx *actor.Context) {
+	for id, pid := range r.clients {
+		silence := time.Duration(r.unanswered[id]) * r.beat.Interval
+		if silence >= r.beat.Timeout {
+			r.logger.Info("client timed out", "client", id, "silence", silence)
+			r.drop(ctx, id)
+			continue
+		}
+		if r.unanswered[id] >= missed_heartbeats && r.presence[id] == online {
+			r.setPresence(ctx, id, away)
+		}
+
+		ctx.Send(pid, &types.Heartbeat{})
+		r.unanswered[id]++
+	}
+}
+
+// Records that the player was just heard from.
+func (r *room) heardFrom(ctx *actor.Context, id string) {
+	r.unanswered[id] = 0
+	if r.presence[id] == away {
+		r.setPresence(ctx, id, online)
+	}
+}
+
+// Changes the presence of a player and lets everyone else know.
+func (r *room) setPresence(ctx *actor.Context, id string, p presence) {
+	user := r.engine.Player(id)
+	if user == nil || r.presence[id] == p {
+		return
+	}
+
+	r.logger.Info("presence changed", "client", id, "username", user.Name, "presence", p)
+	r.presence[id] = p
+	for other := range r.presence {
+		if other != id {
+			r.send(ctx, other, &types.PresenceChanged{Username: user.Name, Presence: p.String()})
+		}
+	}
+	r.reportPlayers(ctx)
+}
+
+/*
+ * Forgets the connection of a player who left or stopped answering. A
+ * game in progress keeps their seat until they reconnect or the game is
+ * over, otherwise they leave the room.
+ */
+func (r *room) drop(ctx *actor.Context, id string) {
+	user := r.engine.Player(id)
+	pid := r.clients[id]
+	r.logger.Info("client disconnected", "username", user.Name, "pid", pid)
+	if pid != nil {
+		ctx.Send(r.lobby, roomLeft{id: r.id, client: pid.String(), gone: true})
+	}
+
+	if state := r.engine.State(); state != game.Connect && state != game.End {
+		delete(r.clients, id)
+		if pid != nil {
+			delete(r.players, pid.String())
+		}
+		r.setPresence(ctx, id, offline)
+		r.handle(ctx, pid, game.Offline{Player: id})
+		return
+	}
+
+	r.remove(ctx, id)
+}
+
+/*
+ * Lets a player leave the room between games. A game in progress keeps
+ * its players until it is over.
+ */
+func (r *room) leave(ctx *actor.Context, sender *actor.PID, id string) {
+	if state := r.engine.State(); state != game.Connect && state != game.End {
+		ctx.Send(sender, &types.ErrorReply{Msg: fmt.Sprintf("You cannot leave room %v while a game is going on.", r.id)})
+		return
+	}
+
+	r.logger.Info("client left", "username", r.engine.Player(id).Name, "pid", sender)
+	r.remove(ctx, id)
+	ctx.Send(sender, &types.RoomLeft{Room: r.id})
+}
+
+// Removes a player and their connection from the room.
+func (r *room) remove(ctx *actor.Context, id string) {
+	user := r.engine.Player(id)
+	pid := r.clients[id]
+	delete(r.clients, id)
+
+	var client string
+	if pid != nil {
+		client = pid.String()
+		delete(r.players, client)
+	}
+	r.forget(ctx, id, client)
+	r.broadcastMessage(ctx, fmt.Sprintf("%v left the room.", user.Name))
+	r.handle(ctx, pid, game.Leave{Player: id})
+}
+
+/*
+ * Lets the players still offline once the game is over leave the room.
+ * The rematch is played by the players who are there.
+ */
+func (r *room) dismissOffline(ctx *actor.Context) {
+	for id, p := range r.presence {
+		if p != offline {
+			continue
+		}
+
+		user := r.engine.Player(id)
+		r.logger.Info("offline player left the room", "client", id, "username", user.Name)
+		r.forget(ctx, id, "")
+		r.broadcastMessage(ctx, fmt.Sprintf("%v left the room.", user.Name))
+		r.dispatch(ctx, nil, r.engine.Handle(game.Leave{Player: id}))
+	}
+}
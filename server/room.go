@@ -0,0 +1,475 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+	"werewolves-go/game"
+	"werewolves-go/server/utils"
+	"werewolves-go/types"
+
+	"github.com/anthdm/hollywood/actor"
+	"google.golang.org/protobuf/proto"
+)
+
+/*
+ * Define types for different structures used in the server path.
+ * Clients are keyed by the string form of their PID.
+ */
+type clientMap map[string]*actor.PID
+
+// Number of messages kept for a player who lost their connection.
+const missed_limit int = 100
+
+/*
+ * PhaseTimeout is delivered to a room once the deadline of the current
+ * game state has elapsed.
+ */
+type PhaseTimeout struct {
+	Deadline time.Time
+}
+
+/*
+ * roomMessage carries a client message that the lobby routes to a room.
+ * A client joining the room comes with the session token the lobby issued
+ * to it.
+ */
+type roomMessage struct {
+	sender *actor.PID
+	msg    any
+	token  string
+}
+
+/*
+ * roomStatus is sent by a room to the lobby every time it handled an input.
+ */
+type roomStatus struct {
+	id      string
+	players int
+	state   game.State
+	deck    string
+}
+
+/*
+ * roomLeft tells the lobby that a client is no longer part of a room. The
+ * session stays valid when token is empty, the player may reconnect to it.
+ * gone is set when the client lost its connection and its name is free.
+ */
+type roomLeft struct {
+	id     string
+	client string
+	token  string
+	gone   bool
+}
+
+/*
+ * Room structure that initates the clients, game engine and logger
+ * parameters required by a single game.
+ *
+ * A player keeps the id of the client it joined with for the whole game.
+ * Clients are keyed by that id, players maps the PID a client currently
+ * connects from to it and sessions maps session tokens to it. Every player
+ * has a presence, offline players keep the messages they missed.
+ */
+type room struct {
+	id         string
+	lobby      *actor.PID
+	clients    clientMap
+	players    map[string]string
+	sessions   map[string]string
+	presence   map[string]presence
+	unanswered map[string]int
+	missed     map[string][]any
+	engine     *game.Engine
+	logger     *slog.Logger
+	clock      game.Clock
+	timer      game.Timer
+	scheduled  time.Time
+	beat       heartbeat
+	pulse      game.Timer
+	listed     *types.PlayerList
+	journal    []logEntry
+	deck       string
+}
+
+/*
+ * Instantiate a receiver actor for a room owned by the given lobby. Games
+ * are played with the given configuration, players are checked on at the
+ * given heartbeat and every deadline is measured on the given clock.
+ */
+func newRoom(id string, lobby *actor.PID, clock game.Clock, cfg game.Config, beat heartbeat) actor.Producer {
+	return func() actor.Receiver {
+		var spec string
+		if cfg.Deck != nil {
+			spec = cfg.Deck.String()
+		}
+
+		return &room{
+			id:         id,
+			lobby:      lobby,
+			clients:    make(clientMap),
+			players:    make(map[string]string),
+			sessions:   make(map[string]string),
+			presence:   make(map[string]presence),
+			unanswered: make(map[string]int),
+			missed:     make(map[string][]any),
+			engine:     game.NewEngine(cfg, clock.Now()),
+			logger:     slog.Default().With("room", id),
+			clock:      clock,
+			beat:       beat,
+			deck:       spec,
+		}
+	}
+}
+
+/*
+ * Receive messages from the lobby and work through different message types.
+ * Every change to the game happens here, on the actor's mailbox.
+ */
+func (r *room) Receive(ctx *actor.Context) {
+	switch msg := ctx.Message().(type) {
+	case actor.Started:
+		r.schedule(ctx)
+		r.schedulePulse(ctx)
+		r.reportStatus(ctx)
+	case PhaseTimeout:
+		// A timeout for a deadline that has since moved is stale.
+		if !msg.Deadline.Equal(r.engine.Deadline()) {
+			return
+		}
+		// The next state may be cut short to this same deadline.
+		r.scheduled = time.Time{}
+		r.handle(ctx, nil, game.Tick{Now: r.clock.Now()})
+	case presenceCheck:
+		r.checkPresence(ctx)
+		r.schedulePulse(ctx)
+	case actor.Stopped:
+		if r.timer != nil {
+			r.timer.Stop()
+		}
+		if r.pulse != nil {
+			r.pulse.Stop()
+		}
+		if r.engine.State() == game.Closed {
+			return
+		}
+		r.logger.Info("Moderator has chosen to die.")
+		for _, pid := range r.clients {
+			ctx.Send(pid, utils.FormatMessageResponseFromServer(
+				"Moderator has chosen to die. You are safe to leave."))
+		}
+	case roomMessage:
+		r.receiveFrom(ctx, msg.sender, msg.msg, msg.token)
+	case roomQuery:
+		ctx.Respond(r.view(msg))
+	}
+}
+
+/*
+ * Handles a message the given client sent to the lobby.
+ */
+func (r *room) receiveFrom(ctx *actor.Context, sender *actor.PID, message any, token string) {
+	cID := sender.String()
+	id := r.players[cID]
+	if id != "" {
+		r.heardFrom(ctx, id)
+	}
+
+	switch message.(type) {
+	case *types.Message, *types.CastVote, *types.UseHeal, *types.UsePoison, *types.InspectPlayer,
+		*types.ProtectPlayer, *types.ShootPlayer, *types.LinkLovers, *types.RequestRematch, *types.LeaveRoom:
+		// Only players seated in the room take part in the game.
+		if id == "" {
+			r.logger.Warn("message from a client not in the room", "client", cID)
+			ctx.Send(sender, &types.ErrorReply{Msg: fmt.Sprintf("You are not in room %v, reconnect or join it first.", r.id)})
+			return
+		}
+	}
+
+	switch msg := message.(type) {
+	case *types.Message:
+		// Chat is signed by the server, a client may not speak for someone else.
+		if player := r.engine.Player(id); player != nil && msg.Username != "" && msg.Username != player.Name {
+			r.logger.Warn("spoofed username rejected", "from", sender, "username", player.Name, "claimed", msg.Username)
+			ctx.Send(sender, &types.ErrorReply{Msg: fmt.Sprintf("You are %v, you cannot speak as %v.", player.Name, msg.Username)})
+			return
+		}
+
+		if len(msg.Msg) > 0 {
+			r.logger.Info("message received", "msg", msg.Msg, "from", sender)
+			r.handle(ctx, sender, game.Say{Player: id, Text: msg.Msg})
+		} else {
+			r.logger.Info(fmt.Sprintf("%v message was empty. hence dropped.", sender))
+		}
+	case *types.CastVote:
+		r.handle(ctx, sender, game.Vote{Player: id, Target: msg.Target})
+	case *types.UseHeal:
+		r.handle(ctx, sender, game.Heal{Player: id, Target: msg.Target})
+	case *types.UsePoison:
+		r.handle(ctx, sender, game.Poison{Player: id, Target: msg.Target})
+	case *types.InspectPlayer:
+		r.handle(ctx, sender, game.Inspect{Player: id, Target: msg.Target})
+	case *types.ProtectPlayer:
+		r.handle(ctx, sender, game.Protect{Player: id, Target: msg.Target})
+	case *types.ShootPlayer:
+		r.handle(ctx, sender, game.Shoot{Player: id, Target: msg.Target})
+	case *types.LinkLovers:
+		r.handle(ctx, sender, game.Link{Player: id, First: msg.First, Second: msg.Second})
+	case *types.RequestRematch:
+		r.handle(ctx, sender, game.Rematch{Player: id})
+	case *types.Disconnect:
+		if r.engine.Player(id) == nil {
+			r.logger.Warn("unknown user disconnected", "client", cID)
+			return
+		}
+		r.drop(ctx, id)
+	case *types.LeaveRoom:
+		r.leave(ctx, sender, id)
+	case *types.Heartbeat:
+		// Hearing from the client is all a heartbeat is for.
+	case *types.Reconnect:
+		r.reconnect(ctx, sender, msg.Token)
+	case *types.Connect:
+		if _, ok := r.players[cID]; ok {
+			r.logger.Warn("client already connected", "client", sender.GetID())
+			return
+		}
+
+		// Register the client first so that it hears its own connection.
+		r.clients[cID] = sender
+		r.players[cID] = cID
+		r.presence[cID] = online
+		r.handle(ctx, sender, game.Join{Player: cID, Name: msg.Username})
+		if r.engine.Player(cID) == nil {
+			delete(r.clients, cID)
+			delete(r.players, cID)
+			delete(r.presence, cID)
+			delete(r.unanswered, cID)
+			ctx.Send(r.lobby, roomLeft{id: r.id, client: cID, token: token})
+			r.reportStatus(ctx)
+			return
+		}
+
+		r.sessions[token] = cID
+		ctx.Send(sender, &types.Session{Token: token, Room: r.id})
+		r.logger.Info("new client connected",
+			"id", sender.GetID(), "addr", sender.GetAddress(), "sender", sender,
+			"username", msg.Username,
+		)
+	}
+}
+
+/*
+ * Gives the seat of the player holding the session token to the sender.
+ * The player is told who they are, what they missed while they were away
+ * and where the game stands.
+ */
+func (r *room) reconnect(ctx *actor.Context, sender *actor.PID, token string) {
+	id, ok := r.sessions[token]
+	user := r.engine.Player(id)
+	if !ok || user == nil {
+		ctx.Send(sender, &types.ErrorReply{Msg: "Your session has expired. Connect again to join a game."})
+		ctx.Send(r.lobby, roomLeft{id: r.id, client: sender.String(), token: token})
+		return
+	}
+
+	// The connection the player had before is never heard from again.
+	if pid, ok := r.clients[id]; ok {
+		delete(r.players, pid.String())
+	}
+	missed := r.missed[id]
+	delete(r.missed, id)
+	r.clients[id] = sender
+	r.players[sender.String()] = id
+	r.unanswered[id] = 0
+	r.logger.Info("client reconnected", "username", user.Name, "pid", sender, "missed", len(missed))
+
+	ctx.Send(sender, utils.FormatMessageResponseFromServer(fmt.Sprintf("Welcome back %v.", user.Name)))
+	for _, msg := range missed {
+		ctx.Send(sender, msg)
+	}
+	if user.Role != "" {
+		ctx.Send(sender, &types.RoleAssigned{Role: user.Role})
+	}
+	ctx.Send(sender, &types.PhaseChanged{Phase: r.engine.State().String(), Deadline: r.engine.Deadline().UnixMilli()})
+	ctx.Send(sender, r.playerList())
+	r.setPresence(ctx, id, online)
+	r.handle(ctx, sender, game.Online{Player: id})
+}
+
+/*
+ * Drops the session of a player who is no longer part of the room. client
+ * is the connection the player was last heard from, empty when they have
+ * none left.
+ */
+func (r *room) forget(ctx *actor.Context, id string, client string) {
+	for token, player := range r.sessions {
+		if player == id {
+			delete(r.sessions, token)
+			ctx.Send(r.lobby, roomLeft{id: r.id, client: client, token: token})
+		}
+	}
+	delete(r.presence, id)
+	delete(r.unanswered, id)
+	delete(r.missed, id)
+}
+
+/*
+ * Hands an input to the game engine, delivers the resulting events,
+ * schedules the timeout of whatever state the game is now in and lets the
+ * lobby know about it. Replies go to sender.
+ */
+func (r *room) handle(ctx *actor.Context, sender *actor.PID, in game.Input) {
+	r.dispatch(ctx, sender, r.engine.Handle(in))
+	if r.engine.State() == game.End {
+		r.dismissOffline(ctx)
+	}
+	r.reportPlayers(ctx)
+	r.schedule(ctx)
+	r.reportStatus(ctx)
+}
+
+/*
+ * Arranges for a PhaseTimeout to reach the room once the deadline of the
+ * current state elapses. Nothing is scheduled after the game has ended.
+ */
+func (r *room) schedule(ctx *actor.Context) {
+	deadline := r.engine.Deadline()
+	if r.engine.State() == game.Closed || deadline.Equal(r.scheduled) {
+		return
+	}
+
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+
+	engine, pid := ctx.Engine(), ctx.PID()
+	r.scheduled = deadline
+	r.timer = r.clock.AfterFunc(deadline.Sub(r.clock.Now()), func() {
+		engine.Send(pid, PhaseTimeout{Deadline: deadline})
+	})
+}
+
+/*
+ * Tells the lobby who is in the room and how far the game has gone.
+ */
+func (r *room) reportStatus(ctx *actor.Context) {
+	ctx.Send(r.lobby, roomStatus{id: r.id, players: len(r.presence), state: r.engine.State(), deck: r.deck})
+}
+
+/*
+ * Sends the list of players to everyone when it changed since it was last
+ * sent.
+ */
+func (r *room) reportPlayers(ctx *actor.Context) {
+	list := r.playerList()
+	if proto.Equal(list, r.listed) {
+		return
+	}
+
+	r.listed = list
+	r.broadcast(ctx, list)
+}
+
+// Returns who plays in the room, whether they are alive and still there.
+func (r *room) playerList() *types.PlayerList {
+	list := &types.PlayerList{}
+	for _, id := range r.engine.Players() {
+		player := r.engine.Player(id)
+		list.Players = append(list.Players, &types.Player{
+			Username: player.Name,
+			Alive:    player.Status,
+			Presence: r.presence[id].String(),
+		})
+	}
+
+	return list
+}
+
+/*
+ * Delivers the events produced by the game engine to the clients.
+ */
+func (r *room) dispatch(ctx *actor.Context, sender *actor.PID, events []game.Event) {
+	for _, event := range events {
+		switch event := event.(type) {
+		case game.Announcement:
+			r.broadcastMessage(ctx, event.Text)
+		case game.Reply:
+			ctx.Send(sender, utils.FormatMessageResponseFromServer(event.Text))
+		case game.Rejected:
+			ctx.Send(sender, &types.ErrorReply{Msg: event.Text})
+		case game.PrivateMessage:
+			r.sendMessage(ctx, event.Player, event.Text)
+		case game.Chat:
+			for _, player := range event.To {
+				r.logger.Info("forwarding message", "client", player, "msg", event.Text)
+				r.send(ctx, player, &types.Message{Username: event.Name, Msg: event.Text, Channel: event.Channel})
+			}
+		case game.RoleAssigned:
+			r.logger.Info("role assigned", "client", event.Player, "role", event.Role)
+			r.send(ctx, event.Player, &types.RoleAssigned{Role: event.Role})
+		case game.PhaseChanged:
+			r.logger.Info("state changed", "state", event.State, "until", event.Deadline)
+			r.publish(ctx, &types.PhaseChanged{Phase: event.State.String(), Deadline: event.Deadline.UnixMilli()})
+		case game.PlayerKilled:
+			r.logger.Info("player killed", "client", event.Player, "username", event.Name)
+			r.publish(ctx, &types.PlayerEliminated{Username: event.Name})
+		case game.VoteResult:
+			r.logger.Info("town voted", "votes", event.Votes, "eliminated", event.Eliminated)
+			r.publish(ctx, voteResult(event))
+		case game.GameOver:
+			r.logger.Info("game over", "winner", event.Winner)
+			r.publish(ctx, &types.GameOver{Winner: event.Winner})
+		}
+	}
+}
+
+/*
+ * Broadcast message sends messages to all clients and keeps them in the
+ * log of the room.
+ */
+func (r *room) broadcastMessage(ctx *actor.Context, message string) {
+	r.record(message)
+	r.broadcast(ctx, utils.FormatMessageResponseFromServer(message))
+}
+
+/*
+ * Sends a public protocol message to all clients and to whoever watches
+ * the games of the server.
+ */
+func (r *room) publish(ctx *actor.Context, msg proto.Message) {
+	r.broadcast(ctx, msg)
+	ctx.Engine().BroadcastEvent(publicEvent{room: r.id, msg: msg})
+}
+
+// Sends a protocol message to all clients.
+func (r *room) broadcast(ctx *actor.Context, msg any) {
+	for id := range r.presence {
+		r.send(ctx, id, msg)
+	}
+}
+
+/*
+ * Send message sends a message from the server to a single client.
+ */
+func (r *room) sendMessage(ctx *actor.Context, cID string, message string) {
+	r.send(ctx, cID, utils.FormatMessageResponseFromServer(message))
+}
+
+/*
+ * Sends a protocol message to a player. The most recent messages for a
+ * player who lost their connection are kept until they reconnect.
+ */
+func (r *room) send(ctx *actor.Context, id string, msg any) {
+	if pid, ok := r.clients[id]; ok {
+		ctx.Send(pid, msg)
+		return
+	}
+	if r.presence[id] == offline {
+		missed := r.missed[id]
+		if len(missed) == missed_limit {
+			missed = missed[1:]
+		}
+		r.missed[id] = append(missed, msg)
+	}
+}
@@ -0,0 +1,813 @@
+package main
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"werewolves-go/game"
+	"werewolves-go/types"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+/*
+ * Test client that records every message it receives from the server. It
+ * answers heartbeats until it is muted.
+ */
+type testClient struct {
+	name     string
+	role     string
+	pid      *actor.PID
+	server   *actor.PID
+	muted    atomic.Bool
+	msgs     chan string
+	rooms    chan *types.RoomList
+	sessions chan string
+	beats    chan struct{}
+}
+
+func (c *testClient) Receive(ctx *actor.Context) {
+	switch msg := ctx.Message().(type) {
+	case *types.Heartbeat:
+		if !c.muted.Load() {
+			ctx.Send(c.server, &types.Heartbeat{})
+			c.beats <- struct{}{}
+		}
+	case *types.PresenceChanged:
+		c.msgs <- msg.Username + " is " + msg.Presence
+	case *types.Message:
+		c.msgs <- msg.Username + ": " + msg.Msg
+	case *types.ErrorReply:
+		c.msgs <- "error: " + msg.Msg
+	case *types.UsernameRejected:
+		c.msgs <- "rejected: " + msg.Reason
+	case *types.RoleAssigned:
+		c.msgs <- "You are a " + msg.Role
+	case *types.PhaseChanged:
+		c.msgs <- "phase " + msg.Phase
+	case *types.PlayerEliminated:
+		c.msgs <- msg.Username + " was eliminated"
+	case *types.GameOver:
+		c.msgs <- "game over: " + msg.Winner
+	case *types.RoomList:
+		c.rooms <- msg
+	case *types.Session:
+		c.sessions <- msg.Token
+	case *types.RoomLeft:
+		c.msgs <- "left room " + msg.Room
+	}
+}
+
+/*
+ * Harness running a lobby actor on a fake clock together with a set of
+ * local test clients.
+ */
+type harness struct {
+	t       *testing.T
+	engine  *actor.Engine
+	clock   *game.FakeClock
+	server  *actor.PID
+	clients map[string]*testClient
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+
+	return newHarnessWith(t, testConfig())
+}
+
+// Starts a harness whose server runs with the given configuration.
+func newHarnessWith(t *testing.T, cfg config) *harness {
+	t.Helper()
+
+	engine, err := actor.NewEngine(actor.NewEngineConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &harness{
+		t:       t,
+		engine:  engine,
+		clock:   game.NewFakeClock(time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)),
+		clients: make(map[string]*testClient),
+	}
+	h.server = engine.Spawn(newLobby(cfg, h.clock), "server", actor.WithID("primary"))
+	t.Cleanup(func() { engine.Poison(h.server).Wait() })
+
+	return h
+}
+
+/*
+ * Returns the configuration games are tested with. Every game has two
+ * werewolves, a witch and a seer, and one more special role for each player
+ * past the fourth. Players are not checked on.
+ */
+func testConfig() config {
+	cfg := defaultConfig()
+	cfg.Heartbeat = heartbeat{}
+	cfg.Game.Presets = nil
+	for _, preset := range []struct {
+		players int
+		spec    string
+	}{
+		{4, "2 werewolves, 1 witch, 1 seer"},
+		{5, "2 werewolves, 1 witch, 1 seer, 1 hunter"},
+		{6, "2 werewolves, 1 witch, 1 seer, 1 hunter, 1 cupid"},
+		{7, "2 werewolves, 1 witch, 1 seer, 1 hunter, 1 cupid, 1 bodyguard"},
+	} {
+		deck, err := game.ParseDeck(preset.spec)
+		if err != nil {
+			panic(err)
+		}
+		cfg.Game.Presets = append(cfg.Game.Presets, game.Preset{MinPlayers: preset.players, Deck: deck})
+	}
+
+	return cfg
+}
+
+/*
+ * Connects new clients to the given room and waits until all of them are in.
+ */
+func (h *harness) join(room string, names ...string) []*testClient {
+	h.t.Helper()
+
+	var joined []*testClient
+	for _, name := range names {
+		c := h.spawn(name, name)
+		joined = append(joined, c)
+		h.engine.SendWithSender(h.server, &types.Connect{Username: name, Room: room}, c.pid)
+	}
+
+	// Every client hears about the last one connecting.
+	last := names[len(names)-1]
+	for _, c := range joined {
+		h.expect(c, last+" connected")
+	}
+
+	return joined
+}
+
+// Starts a test client for the named player with the given actor id.
+func (h *harness) spawn(name string, id string) *testClient {
+	c := &testClient{
+		name:     name,
+		server:   h.server,
+		msgs:     make(chan string, 1024),
+		rooms:    make(chan *types.RoomList, 16),
+		sessions: make(chan string, 16),
+		beats:    make(chan struct{}, 1024),
+	}
+	c.pid = h.engine.Spawn(func() actor.Receiver { return c }, "client", actor.WithID(id))
+	h.clients[name] = c
+
+	return c
+}
+
+/*
+ * Connects a new client that takes back the seat of the given one with
+ * the given session token. The new client replaces the old one.
+ */
+func (h *harness) reconnect(c *testClient, token string) *testClient {
+	again := h.spawn(c.name, c.name+"-again")
+	again.role = c.role
+	h.send(again, &types.Reconnect{Token: token})
+
+	return again
+}
+
+// Waits for the session token the client receives once it joined a room.
+func (h *harness) expectSession(c *testClient) string {
+	h.t.Helper()
+
+	select {
+	case token := <-c.sessions:
+		return token
+	case <-time.After(2 * time.Second):
+		h.t.Fatalf("%v never received a session token", c.name)
+	}
+
+	return ""
+}
+
+// Sends a message to the lobby as the given client.
+func (h *harness) send(c *testClient, msg any) {
+	h.engine.SendWithSender(h.server, msg, c.pid)
+}
+
+// Sends a line of text to the server as the given client.
+func (h *harness) say(c *testClient, text string) {
+	h.send(c, &types.Message{Username: c.name, Msg: text})
+}
+
+// Votes against the target as the given client.
+func (h *harness) vote(c *testClient, target string) {
+	h.send(c, &types.CastVote{Target: target})
+}
+
+// Waits until the client receives a message containing text.
+func (h *harness) expect(c *testClient, text string) string {
+	h.t.Helper()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case msg := <-c.msgs:
+			if strings.Contains(msg, text) {
+				return msg
+			}
+		case <-timeout:
+			h.t.Fatalf("%v never received %q", c.name, text)
+		}
+	}
+}
+
+// Waits until every client receives a message containing text.
+func (h *harness) expectAll(text string) {
+	h.t.Helper()
+
+	for _, c := range h.clients {
+		h.expect(c, text)
+	}
+}
+
+/*
+ * Jumps to the deadline of the current state as soon as the room has
+ * scheduled it. Messages still waiting in the lobby are routed first, so
+ * that they reach the room before the timeout does. Inputs that cut a state
+ * short must have been answered before calling next, or the timeout of the
+ * old deadline fires and is ignored as stale.
+ */
+func (h *harness) next() {
+	h.t.Helper()
+
+	if _, err := h.engine.Request(h.server, &types.ListRooms{}, 2*time.Second).Result(); err != nil {
+		h.t.Fatal(err)
+	}
+
+	timeout := time.Now().Add(2 * time.Second)
+	for !h.clock.Next() {
+		if time.Now().After(timeout) {
+			h.t.Fatal("server never scheduled a deadline")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+/*
+ * Moves the clock forward by one heartbeat interval and waits until the
+ * given clients answered the heartbeat. Like next, messages waiting in the
+ * lobby are routed first.
+ */
+func (h *harness) beat(interval time.Duration, clients ...*testClient) {
+	h.t.Helper()
+
+	if _, err := h.engine.Request(h.server, &types.ListRooms{}, 2*time.Second).Result(); err != nil {
+		h.t.Fatal(err)
+	}
+	h.clock.Advance(interval)
+
+	for _, c := range clients {
+		select {
+		case <-c.beats:
+		case <-time.After(2 * time.Second):
+			h.t.Fatalf("%v never received a heartbeat", c.name)
+		}
+	}
+}
+
+/*
+ * Ends the connection window and learns which role every client received.
+ */
+func (h *harness) assignRoles(clients []*testClient) map[string][]*testClient {
+	h.t.Helper()
+
+	h.next()
+	return h.learnRoles(clients)
+}
+
+// Learns which role every client received.
+func (h *harness) learnRoles(clients []*testClient) map[string][]*testClient {
+	h.t.Helper()
+
+	roles := make(map[string][]*testClient)
+	for _, c := range clients {
+		msg := h.expect(c, "You are a ")
+		c.role = strings.Fields(strings.SplitAfter(msg, "You are a ")[1])[0]
+		roles[c.role] = append(roles[c.role], c)
+	}
+
+	if len(roles[game.Werewolf]) != 2 || len(roles[game.Witch]) != 1 || len(roles[game.Seer]) != 1 {
+		h.t.Fatalf("unexpected roles %v", roles)
+	}
+
+	return roles
+}
+
+/*
+ * Plays the shortest game the werewolves can win: they eat the seer on the
+ * first night and vote the witch out the next day.
+ */
+func (h *harness) playWerewolvesWin(roles map[string][]*testClient) {
+	h.t.Helper()
+
+	wolves, witch, town := roles[game.Werewolf], roles[game.Witch][0], roles[game.Seer][0]
+
+	h.expectAll("Werewolves, open your eyes.")
+	h.next()
+	for _, wolf := range wolves {
+		h.expect(wolf, "Choose the player to kill")
+		h.vote(wolf, town.name)
+	}
+
+	h.next()
+	h.expect(town, "Choose the player to inspect")
+	h.send(town, &types.InspectPlayer{Target: wolves[0].name})
+	h.expect(town, wolves[0].name+" is a werewolf!")
+
+	h.next()
+	h.expect(witch, "Type /heal "+town.name+" to save them or /pass to skip")
+	h.send(witch, &types.UseHeal{})
+	h.expectAll("Witch has chosen to pass.")
+
+	h.next()
+	h.expectAll("The werewolf chose to kill " + town.name)
+
+	h.next()
+	h.expect(witch, "Choose the player to kick out")
+	for _, wolf := range wolves {
+		h.vote(wolf, witch.name)
+	}
+	h.vote(witch, wolves[0].name)
+
+	h.next()
+	h.expectAll("The town has chosen to kill " + witch.name)
+	h.expectAll(witch.name + " was eliminated")
+	h.expectAll("game over: Werewolves win")
+}
+
+func TestWerewolvesWin(t *testing.T) {
+	started := time.Now()
+	h := newHarness(t)
+	h.playWerewolvesWin(h.assignRoles(h.join("main", "a", "b", "c", "d")))
+
+	// Nobody asks for a rematch, so the room closes.
+	h.expectAll("Type /rematch")
+	h.next()
+	h.expectAll("Room main is closed")
+
+	if elapsed := time.Now().Sub(started); elapsed > 5*time.Second {
+		t.Errorf("game took %v of real time", elapsed)
+	}
+}
+
+func TestRematch(t *testing.T) {
+	h := newHarness(t)
+	players := h.join("main", "a", "b", "c", "d")
+	h.playWerewolvesWin(h.assignRoles(players))
+
+	// A newcomer joins the finished game and dead players can talk again.
+	players = append(players, h.join("main", "e")...)
+	h.say(players[0], "gg")
+	h.expect(players[4], "gg")
+
+	h.send(players[0], &types.RequestRematch{})
+	h.send(players[0], &types.RequestRematch{})
+	h.expect(players[0], "error: You already asked for a rematch.")
+	h.send(players[1], &types.RequestRematch{})
+	h.send(players[4], &types.RequestRematch{})
+	h.expectAll("e wants a rematch (3/5)")
+
+	h.next()
+	h.expectAll("Rematch! A new game begins")
+
+	// Roles are dealt again to everyone, newcomer included.
+	h.assignRoles(players)
+	h.expectAll("========== Round: 1 ==========")
+}
+
+func TestRematchWithoutOfflinePlayers(t *testing.T) {
+	h := newHarness(t)
+	roles := h.assignRoles(h.join("main", "a", "b", "c", "d"))
+	wolves, witch, seer := roles[game.Werewolf], roles[game.Witch][0], roles[game.Seer][0]
+
+	// The seer is eaten on the first night and never comes back.
+	h.expect(witch, "Werewolves, open your eyes.")
+	h.next()
+	for _, wolf := range wolves {
+		h.vote(wolf, seer.name)
+	}
+	h.send(seer, &types.Disconnect{})
+	h.expect(witch, seer.name+" is offline")
+
+	h.next()
+	h.next()
+	h.expect(witch, "Type /heal "+seer.name+" to save them")
+	h.send(witch, &types.UseHeal{})
+	h.next()
+	h.expect(witch, "The werewolf chose to kill "+seer.name)
+
+	h.next()
+	for _, wolf := range wolves {
+		h.vote(wolf, witch.name)
+	}
+	h.next()
+	h.expect(witch, "game over: Werewolves win")
+	h.expect(witch, seer.name+" left the room.")
+
+	// The seer neither counts for the rematch nor is dealt a new role.
+	h.send(wolves[0], &types.RequestRematch{})
+	h.expect(witch, wolves[0].name+" wants a rematch (1/3)")
+	h.send(wolves[1], &types.RequestRematch{})
+	h.expect(witch, wolves[1].name+" wants a rematch (2/3)")
+	h.next()
+	h.expect(witch, "Rematch! A new game begins")
+
+	h.send(witch, &types.ListRooms{})
+	if list := h.expectRooms(witch); list.Rooms[0].Players != 3 {
+		t.Errorf("rematch has %d players, the offline seer is still seated", list.Rooms[0].Players)
+	}
+}
+
+func TestTownspeopleWin(t *testing.T) {
+	h := newHarness(t)
+	roles := h.assignRoles(h.join("main", "a", "b", "c", "d"))
+	wolves, witch, town := roles[game.Werewolf], roles[game.Witch][0], roles[game.Seer][0]
+
+	// The werewolves cannot agree, so nobody dies on the first night.
+	h.next()
+	h.vote(wolves[0], town.name)
+	h.vote(wolves[1], witch.name)
+
+	// Only the seer may speak while having a vision, and only once.
+	h.next()
+	h.say(wolves[1], "hello")
+	h.expect(wolves[1], "error: You are not allowed to send messages in seerinspect")
+	h.send(town, &types.InspectPlayer{Target: witch.name})
+	h.expect(town, witch.name+" is not a werewolf.")
+	h.send(town, &types.InspectPlayer{Target: wolves[0].name})
+	h.expect(town, "You already had your vision tonight.")
+
+	// The witch keeps her poison for later.
+	h.next()
+	h.expect(witch, "Type /poison <name> to poison")
+	h.send(witch, &types.UseHeal{})
+
+	h.next()
+	h.expectAll("the werewolf did not feed tonight")
+
+	h.next()
+	h.expect(town, "Choose the player to kick out")
+	for _, c := range []*testClient{town, witch, wolves[1]} {
+		h.vote(c, wolves[0].name)
+	}
+	h.vote(wolves[0], town.name)
+
+	// Chat is never counted as a vote, or this one would tie the vote.
+	h.say(witch, town.name)
+
+	// A lone werewolf skips the discussion and goes straight to voting.
+	h.next()
+	h.expectAll("The town has chosen to kill " + wolves[0].name)
+	h.expect(wolves[1], "Choose the player to kill")
+	h.vote(wolves[1], town.name)
+
+	h.next()
+	h.expect(town, "Choose the player to inspect")
+
+	// The witch saves the seer and poisons the last werewolf.
+	h.next()
+	h.expect(witch, "The werewolves chose to kill "+town.name)
+	h.send(witch, &types.UseHeal{Target: town.name})
+	h.send(witch, &types.UsePoison{Target: wolves[1].name})
+	h.expect(witch, wolves[1].name+" will not see the morning.")
+	h.send(witch, &types.UsePoison{Target: town.name})
+	h.expect(witch, "No poison potions left!")
+
+	// Dead players are not allowed to talk.
+	h.say(wolves[0], "hello")
+	h.expect(wolves[0], "you cant message when you are dead")
+
+	h.next()
+	h.expectAll("The witch saved a person from being killed")
+	h.expectAll(wolves[1].name + " was found poisoned")
+	h.expectAll("Townspeople win")
+}
+
+func TestHunterShootsAtNight(t *testing.T) {
+	h := newHarness(t)
+	roles := h.assignRoles(h.join("main", "a", "b", "c", "d", "e"))
+	wolves, witch, hunter := roles[game.Werewolf], roles[game.Witch][0], roles[game.Hunter][0]
+
+	h.next()
+	for _, wolf := range wolves {
+		h.vote(wolf, hunter.name)
+	}
+
+	h.next()
+	h.next()
+	h.send(witch, &types.UseHeal{})
+
+	// The dead hunter alone may speak and takes a werewolf down.
+	h.next()
+	h.expectAll("The werewolf chose to kill " + hunter.name)
+	h.expect(hunter, "Choose the player to shoot")
+	h.say(witch, "hello")
+	h.expect(witch, "You are not allowed to send messages in huntershot")
+	h.send(hunter, &types.ShootPlayer{Target: wolves[0].name})
+	h.expectAll("The hunter " + hunter.name + " took " + wolves[0].name + " down with them")
+
+	h.next()
+	h.expectAll("You have 2m0s time to discuss")
+}
+
+func TestHunterVotedOut(t *testing.T) {
+	h := newHarness(t)
+	players := h.join("main", "a", "b", "c", "d", "e")
+	roles := h.assignRoles(players)
+	wolves, witch, hunter := roles[game.Werewolf], roles[game.Witch][0], roles[game.Hunter][0]
+
+	// Nobody dies on the first night.
+	h.next()
+	h.vote(wolves[0], witch.name)
+	h.vote(wolves[1], hunter.name)
+	h.next()
+	h.next()
+	h.send(witch, &types.UseHeal{})
+	h.next()
+	h.expectAll("the werewolf did not feed tonight")
+
+	h.next()
+	for _, c := range players {
+		h.vote(c, hunter.name)
+	}
+
+	// The hunter lets the window pass and the next night begins.
+	h.next()
+	h.expectAll("The town has chosen to kill " + hunter.name)
+	h.expect(hunter, "Choose the player to shoot")
+
+	h.next()
+	h.expectAll("The hunter did not shoot")
+	h.expectAll("========== Round: 2 ==========")
+}
+
+func TestLoversDieTogether(t *testing.T) {
+	h := newHarness(t)
+	roles := h.assignRoles(h.join("main", "a", "b", "c", "d", "e", "f"))
+	wolves, witch, seer, hunter, cupid :=
+		roles[game.Werewolf], roles[game.Witch][0], roles[game.Seer][0], roles[game.Hunter][0], roles[game.Cupid][0]
+
+	h.expect(cupid, "Choose two players to fall in love")
+	h.send(cupid, &types.LinkLovers{First: seer.name, Second: hunter.name})
+	h.expect(seer, "You are in love with "+hunter.name)
+	h.expect(hunter, "You are in love with "+seer.name)
+
+	h.next()
+	h.expectAll("Werewolves, open your eyes.")
+	h.next()
+	for _, wolf := range wolves {
+		h.vote(wolf, seer.name)
+	}
+	h.next()
+	h.next()
+	h.send(witch, &types.UseHeal{})
+
+	// The hunter dies of grief and still gets to shoot.
+	h.next()
+	h.expectAll("The werewolf chose to kill " + seer.name)
+	h.expectAll(hunter.name + " died of grief")
+	h.send(hunter, &types.ShootPlayer{Target: wolves[0].name})
+	h.expectAll("The hunter " + hunter.name + " took " + wolves[0].name + " down with them")
+
+	h.next()
+	h.expectAll("You have 2m0s time to discuss")
+}
+
+func TestLoversWin(t *testing.T) {
+	h := newHarness(t)
+	players := h.join("main", "a", "b", "c", "d", "e", "f")
+	roles := h.assignRoles(players)
+	wolves, witch, seer, hunter, cupid :=
+		roles[game.Werewolf], roles[game.Witch][0], roles[game.Seer][0], roles[game.Hunter][0], roles[game.Cupid][0]
+
+	h.send(cupid, &types.LinkLovers{First: cupid.name, Second: wolves[0].name})
+	h.expect(wolves[0], "You are in love with "+cupid.name)
+
+	// The seer is eaten and the witch poisons the other werewolf.
+	h.next()
+	h.next()
+	for _, wolf := range wolves {
+		h.vote(wolf, seer.name)
+	}
+	h.next()
+	h.next()
+	h.send(witch, &types.UsePoison{Target: wolves[1].name})
+	h.next()
+	h.expectAll(wolves[1].name + " was found poisoned")
+
+	// The hunter is voted out and shoots the witch, leaving the lovers alone.
+	h.next()
+	for _, c := range players {
+		h.vote(c, hunter.name)
+	}
+	h.next()
+	h.send(hunter, &types.ShootPlayer{Target: witch.name})
+	h.expectAll("The hunter " + hunter.name + " took " + witch.name + " down with them")
+
+	h.next()
+	h.expectAll("The lovers win")
+}
+
+func TestBodyguard(t *testing.T) {
+	h := newHarness(t)
+	roles := h.assignRoles(h.join("main", "a", "b", "c", "d", "e", "f", "g"))
+	wolves, seer, bodyguard := roles[game.Werewolf], roles[game.Seer][0], roles[game.Bodyguard][0]
+
+	// The bodyguard watches over the seer, whom the werewolves try to eat.
+	h.next()
+	h.next()
+	for _, wolf := range wolves {
+		h.vote(wolf, seer.name)
+	}
+	h.next()
+	h.next()
+	h.expect(bodyguard, "Choose the player to protect")
+	h.send(bodyguard, &types.ProtectPlayer{Target: seer.name})
+	h.expect(bodyguard, "You will watch over "+seer.name+" tonight.")
+	h.next()
+	h.next()
+	h.expectAll("The bodyguard protected a person from being killed")
+
+	// Nobody is voted out and the bodyguard cannot pick the seer again.
+	h.next()
+	h.next()
+	h.next()
+	h.next()
+	h.next()
+	h.expect(bodyguard, "Choose the player to protect")
+	h.send(bodyguard, &types.ProtectPlayer{Target: seer.name})
+	h.expect(bodyguard, "You cannot protect "+seer.name+" two nights in a row.")
+}
+
+func TestSpoofedUsernameIsRejected(t *testing.T) {
+	h := newHarness(t)
+	players := h.join("main", "a", "b", "c", "d")
+	roles := h.assignRoles(players)
+	wolves, seer := roles[game.Werewolf], roles[game.Seer][0]
+
+	// The seer cannot sneak into the werewolves' discussion under a wolf's name.
+	h.expectAll("Werewolves, open your eyes.")
+	h.send(seer, &types.Message{Username: wolves[0].name, Msg: "who do we eat?"})
+	h.expect(seer, "error: You are "+seer.name+", you cannot speak as "+wolves[0].name+".")
+	h.send(seer, &types.Connect{Username: wolves[0].name, Room: "main"})
+	h.expect(seer, "error: You are already connected as "+seer.name+".")
+
+	// Chat is signed with the name of its sender, and the other werewolf
+	// never heard the spoofed message.
+	h.send(wolves[0], &types.Message{Msg: "the seer"})
+	for {
+		msg := h.expect(wolves[1], "")
+		if strings.Contains(msg, "who do we eat?") {
+			t.Fatalf("%v received the spoofed message", wolves[1].name)
+		} else if strings.Contains(msg, "the seer") {
+			if msg != wolves[0].name+": the seer" {
+				t.Errorf("chat was relayed as %q", msg)
+			}
+			break
+		}
+	}
+}
+
+func TestConnectionWindowIsExtended(t *testing.T) {
+	h := newHarness(t)
+	h.join("main", "a", "b")
+
+	h.next()
+	h.expectAll("Minimum player not reached. Extending time....")
+
+	h.next()
+	h.expectAll("Minimum player not reached. Extending time....")
+}
+
+func TestReconnect(t *testing.T) {
+	h := newHarness(t)
+	roles := h.assignRoles(h.join("main", "a", "b", "c", "d"))
+	wolves, witch, seer := roles[game.Werewolf], roles[game.Witch][0], roles[game.Seer][0]
+	token := h.expectSession(witch)
+
+	// The witch drops during the night and keeps her seat.
+	h.expectAll("Werewolves, open your eyes.")
+	h.send(seer, &types.LeaveRoom{})
+	h.expect(seer, "error: You cannot leave room main while a game is going on.")
+	h.send(witch, &types.Disconnect{})
+	h.expect(seer, witch.name+" is offline")
+
+	h.next()
+	for _, wolf := range wolves {
+		h.vote(wolf, seer.name)
+	}
+	h.next()
+	h.expect(seer, "Choose the player to inspect")
+	h.next()
+	h.expect(seer, "phase witchheal")
+
+	h.send(seer, &types.ListRooms{})
+	if list := h.expectRooms(seer); list.Rooms[0].Players != 4 {
+		t.Errorf("room has %d players while the witch is away", list.Rooms[0].Players)
+	}
+
+	// Unknown tokens are refused.
+	stranger := h.spawn("stranger", "stranger")
+	h.send(stranger, &types.Reconnect{Token: "nope"})
+	h.expect(stranger, "error: Your session has expired.")
+
+	// Back on a new connection, she hears what she missed and where the game stands.
+	witch = h.reconnect(witch, token)
+	h.expect(witch, "Welcome back "+witch.name)
+	h.expect(witch, "Type /heal "+seer.name+" to save them")
+	h.expect(witch, "You are a witch")
+	h.expect(witch, "phase witchheal")
+	h.expect(seer, witch.name+" is online")
+
+	h.send(witch, &types.UseHeal{})
+	h.expect(seer, "Witch has chosen to pass.")
+	h.next()
+	h.expect(witch, "The werewolf chose to kill "+seer.name)
+	h.say(witch, "I am back")
+	h.expect(wolves[0], witch.name+": I am back")
+}
+
+func TestPresence(t *testing.T) {
+	cfg := testConfig()
+	cfg.Heartbeat = heartbeat{Interval: time.Second, Timeout: 3 * time.Second}
+	cfg.Game.Offline = game.SkipOffline
+	cfg.Game.ConnectionDuration = 2 * time.Second
+	cfg.Game.WerewolfDiscussion = 2 * time.Second
+	cfg.Game.VotingDuration = 4 * time.Second
+	cfg.Game.SeerDuration = time.Minute
+	h := newHarnessWith(t, cfg)
+	players := h.join("main", "a", "b", "c", "d")
+
+	// The connection window closes with the second heartbeat.
+	h.beat(time.Second, players...)
+	h.beat(time.Second, players...)
+	roles := h.learnRoles(players)
+	wolves, witch, seer := roles[game.Werewolf], roles[game.Witch][0], roles[game.Seer][0]
+	others := []*testClient{wolves[0], wolves[1], witch}
+
+	// The seer stops answering, is taken for away and then for offline.
+	seer.muted.Store(true)
+	h.beat(time.Second, others...)
+	h.beat(time.Second, others...)
+	for _, wolf := range wolves {
+		h.expect(wolf, "Choose the player to kill")
+		h.vote(wolf, witch.name)
+	}
+	h.beat(time.Second, others...)
+	h.expect(witch, seer.name+" is away")
+	h.beat(time.Second, others...)
+	h.expect(witch, seer.name+" is offline")
+
+	// The night does not wait for the offline seer.
+	h.beat(time.Second, others...)
+	h.beat(time.Second, others...)
+	h.expect(witch, "phase seerinspect")
+	h.beat(time.Second, others...)
+	h.expect(witch, "phase witchheal")
+	h.expect(witch, "Type /heal "+witch.name+" to save them")
+
+	h.send(witch, &types.ListRooms{})
+	if list := h.expectRooms(witch); list.Rooms[0].Players != 4 {
+		t.Errorf("room has %d players while the seer is offline", list.Rooms[0].Players)
+	}
+
+	// Back from the same address, the seer takes their seat again.
+	token := h.expectSession(seer)
+	seer.muted.Store(false)
+	h.send(seer, &types.Reconnect{Token: token})
+	h.expect(seer, "Welcome back "+seer.name)
+	h.expect(witch, seer.name+" is online")
+}
+
+func TestReconnectedPlayerTimesOutBeforeTheGame(t *testing.T) {
+	cfg := testConfig()
+	cfg.Heartbeat = heartbeat{Interval: time.Second, Timeout: 3 * time.Second}
+	cfg.Game.ConnectionDuration = time.Minute
+	h := newHarnessWith(t, cfg)
+	players := h.join("main", "a", "b")
+
+	a := h.reconnect(players[0], h.expectSession(players[0]))
+	h.expect(a, "Welcome back a")
+
+	// The new connection of a stops answering and a leaves the room.
+	a.muted.Store(true)
+	for i := 0; i < 5; i++ {
+		h.beat(time.Second, players[1])
+	}
+	h.expect(players[1], "a left the room.")
+
+	// Connecting again, it is no longer taken for a member of the room.
+	h.send(a, &types.Connect{Username: "a", Room: "main"})
+	h.expect(a, "a connected")
+}
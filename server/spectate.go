@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+	"werewolves-go/game"
+	"werewolves-go/types"
+
+	"github.com/anthdm/hollywood/actor"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Number of events kept for a spectator who does not read them fast enough.
+const spectator_backlog int = 64
+
+// Time between two comments keeping an idle event stream open.
+const keepalive_interval time.Duration = 15 * time.Second
+
+/*
+ * publicEvent is a protocol message a room sent to all its players, which
+ * anyone may watch. Rooms broadcast them on the event stream of the actor
+ * engine.
+ */
+type publicEvent struct {
+	room string
+	msg  proto.Message
+}
+
+/*
+ * Encodes the event like the gateway encodes messages, along with the room
+ * it happened in.
+ */
+func (e publicEvent) MarshalJSON() ([]byte, error) {
+	data, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(e.msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(struct {
+		Room string `json:"room"`
+		envelope
+	}{e.room, envelope{Type: string(e.msg.ProtoReflect().Descriptor().Name()), Data: data}})
+}
+
+// Converts the result of a town vote, the most voted for player first.
+func voteResult(event game.VoteResult) *types.VoteResult {
+	result := &types.VoteResult{Eliminated: event.Eliminated}
+	for username, votes := range event.Votes {
+		result.Votes = append(result.Votes, &types.VoteCount{Username: username, Votes: int32(votes)})
+	}
+	sort.Slice(result.Votes, func(i, j int) bool {
+		a, b := result.Votes[i], result.Votes[j]
+		return a.Votes > b.Votes || a.Votes == b.Votes && a.Username < b.Username
+	})
+
+	return result
+}
+
+/*
+ * spectator is the actor standing for an open event stream. It hands the
+ * public events of the watched room, or of every room when it is empty, to
+ * the HTTP handler writing the stream.
+ */
+type spectator struct {
+	room   string
+	events chan publicEvent
+	logger *slog.Logger
+}
+
+func (s *spectator) Receive(ctx *actor.Context) {
+	msg, ok := ctx.Message().(publicEvent)
+	if !ok || s.room != "" && msg.room != s.room {
+		return
+	}
+
+	select {
+	case s.events <- msg:
+	default:
+		s.logger.Warn("event dropped for a slow spectator", "room", msg.room)
+	}
+}
+
+/*
+ * Streams the public events of the games as server-sent events, named
+ * after the type of their message. The room query parameter restricts the
+ * stream to a single room.
+ */
+func spectate(engine *actor.Engine) http.HandlerFunc {
+	logger := slog.Default().With("http", "events")
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		rc := http.NewResponseController(w)
+		room := req.URL.Query().Get("room")
+		events := make(chan publicEvent, spectator_backlog)
+		pid := engine.Spawn(func() actor.Receiver {
+			return &spectator{room: room, events: events, logger: logger}
+		}, "spectator")
+		// Subscribing before answering means no event is missed once the stream is open.
+		engine.Subscribe(pid)
+		defer func() {
+			engine.Unsubscribe(pid)
+			engine.Poison(pid)
+		}()
+		logger.Info("spectator connected", "room", room, "addr", req.RemoteAddr)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		rc.Flush()
+
+		keepalive := time.NewTicker(keepalive_interval)
+		defer keepalive.Stop()
+		for {
+			var err error
+			select {
+			case <-req.Context().Done():
+				logger.Info("spectator disconnected", "room", room, "addr", req.RemoteAddr)
+				return
+			case <-keepalive.C:
+				_, err = fmt.Fprint(w, ": keepalive\n\n")
+			case event := <-events:
+				var data []byte
+				if data, err = json.Marshal(event); err == nil {
+					_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.msg.ProtoReflect().Descriptor().Name(), data)
+				}
+			}
+			if err == nil {
+				err = rc.Flush()
+			}
+			if err != nil {
+				logger.Warn("event stream closed", "room", room, "err", err)
+				return
+			}
+		}
+	}
+}
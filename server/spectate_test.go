@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Opens the event stream of the given test server and returns its events, one "name data" line each.
+func watch(t *testing.T, srv *httptest.Server, path string) <-chan string {
+	t.Helper()
+
+	resp, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("GET %v = %v %v", path, resp.Status, resp.Header.Get("Content-Type"))
+	}
+
+	events := make(chan string, 100)
+	go func() {
+		var name string
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if value, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+				name = value
+			} else if value, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				events <- name + " " + value
+			}
+		}
+		close(events)
+	}()
+
+	return events
+}
+
+// Waits for an event of the given name containing text. Events that are not public fail the test.
+func expectEvent(t *testing.T, events <-chan string, name string, text string) {
+	t.Helper()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if !strings.HasPrefix(event, "PhaseChanged ") && !strings.HasPrefix(event, "PlayerEliminated ") &&
+				!strings.HasPrefix(event, "VoteResult ") && !strings.HasPrefix(event, "GameOver ") {
+				t.Fatalf("spectators received %v", event)
+			}
+			if strings.HasPrefix(event, name+" ") && strings.Contains(event, text) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("never received a %v event containing %q", name, text)
+		}
+	}
+}
+
+func TestSpectate(t *testing.T) {
+	h := newHarness(t)
+	srv := httptest.NewServer(newMux(h.engine, h.server, ""))
+	// Closed after the stream, the server waits for the requests it serves.
+	t.Cleanup(srv.Close)
+
+	events := watch(t, srv, "/api/events?room=main")
+	roles := h.assignRoles(h.join("main", "a", "b", "c", "d"))
+
+	expectEvent(t, events, "PhaseChanged", `{"room":"main","type":"PhaseChanged","data":{"phase":"start"`)
+	h.playWerewolvesWin(roles)
+
+	town, witch := roles["seer"][0].name, roles["witch"][0].name
+	expectEvent(t, events, "PlayerEliminated", `"data":{"username":"`+town+`"}`)
+	expectEvent(t, events, "VoteResult", `"votes":[{"username":"`+witch+`","votes":2},`)
+	expectEvent(t, events, "PlayerEliminated", `"data":{"username":"`+witch+`"}`)
+	expectEvent(t, events, "PhaseChanged", `"phase":"end"`)
+	expectEvent(t, events, "GameOver", `"winner":"Werewolves win"`)
+}
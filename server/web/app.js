@@ -0,0 +1,297 @@
+"use strict";
+
+// Browser client of the werewolves server. It speaks the JSON messages of
+// the WebSocket gateway, see the Readme.
+
+const $ = (id) => document.getElementById(id);
+
+const state = {
+	socket: null,
+	username: "",
+	role: "",
+	phase: "",
+	deadline: 0,
+	players: [],
+	lovers: [],
+	channel: "game",
+};
+
+// What the players of a role can do to others during a phase.
+const actions = {
+	werewolfvote: { werewolf: [["Kill", "CastVote"]] },
+	townspersonvote: { "*": [["Vote", "CastVote"]] },
+	seerinspect: { seer: [["Inspect", "InspectPlayer"]] },
+	bodyguardprotect: { bodyguard: [["Protect", "ProtectPlayer"]] },
+	witchheal: { witch: [["Heal", "UseHeal"], ["Poison", "UsePoison"]] },
+	huntershot: { hunter: [["Shoot", "ShootPlayer"]] },
+	cupidlink: { cupid: [["Love", "LinkLovers"]] },
+};
+
+// Phases only some roles are awake in, chat then goes to their channel.
+const nightPhases = ["cupidlink", "werewolfdiscuss", "werewolfvote", "seerinspect", "bodyguardprotect", "witchheal"];
+
+function send(type, data = {}) {
+	state.socket.send(JSON.stringify({ type, data }));
+}
+
+function connect(first) {
+	const scheme = location.protocol === "https:" ? "wss:" : "ws:";
+	state.socket = new WebSocket(`${scheme}//${location.host}/ws`);
+	state.socket.onopen = first;
+	state.socket.onmessage = (event) => {
+		const { type, data } = JSON.parse(event.data);
+		(handlers[type] || (() => {}))(data);
+	};
+	state.socket.onclose = () => {
+		if (!$("game").hidden) {
+			line("game", "", "Connection lost, reconnecting...");
+			setTimeout(() => connect(reconnect), 2000);
+		}
+	};
+}
+
+function reconnect() {
+	send("Reconnect", { token: localStorage.getItem("token") });
+}
+
+const handlers = {
+	Heartbeat() {
+		send("Heartbeat");
+	},
+	Session({ token, room }) {
+		localStorage.setItem("token", token);
+		$("login").hidden = true;
+		$("game").hidden = false;
+		$("whoami").textContent = `${state.username} in ${room}`;
+		localStorage.setItem("username", state.username);
+	},
+	RoomLeft({ room }) {
+		localStorage.removeItem("token");
+		showLogin(`You left room ${room}.`);
+	},
+	UsernameRejected({ reason }) {
+		$("login-error").textContent = reason;
+	},
+	ErrorReply({ msg }) {
+		if (msg.startsWith("Your session has expired")) {
+			localStorage.removeItem("token");
+			showLogin(msg);
+			return;
+		}
+		line(state.channel, "", msg, "error");
+	},
+	Message({ username, msg, channel }) {
+		if (username === "server/primary") {
+			if (msg.startsWith("Welcome back")) {
+				$("login").hidden = true;
+				$("game").hidden = false;
+				$("whoami").textContent = state.username;
+			}
+			line("game", "", msg);
+		} else {
+			line(channel || "town", username, msg);
+		}
+	},
+	RoleAssigned({ role }) {
+		state.role = role;
+		$("role").textContent = role;
+		render();
+	},
+	PhaseChanged({ phase, deadline }) {
+		state.phase = phase;
+		state.deadline = Number(deadline);
+		state.lovers = [];
+		$("phase").textContent = phase;
+		render();
+	},
+	PlayerList({ players }) {
+		state.players = players;
+		render();
+	},
+	PlayerEliminated({ username }) {
+		line("game", "", `${username} was eliminated`);
+	},
+	VoteResult({ votes }) {
+		line("game", "", "Votes: " + votes.map((count) => `${count.username} ${count.votes}`).join(", "));
+	},
+	GameOver({ winner }) {
+		line("game", "", `Game over: ${winner}`);
+	},
+	PresenceChanged({ username, presence }) {
+		line("game", "", `${username} is ${presence}`);
+	},
+	RoomList({ rooms }) {
+		if (rooms.length === 0) {
+			line("game", "", "No rooms yet.");
+		}
+		for (const room of rooms) {
+			line("game", "", `room ${room.id}: ${room.players} players, ${room.state}${room.deck ? ", deck " + room.deck : ""}`);
+		}
+	},
+};
+
+function showLogin(error) {
+	$("game").hidden = true;
+	$("login").hidden = false;
+	$("login-error").textContent = error || "";
+}
+
+// Returns the pane of a chat channel, creating it and its tab on first use.
+function pane(channel) {
+	let pane = document.querySelector(`.pane[data-channel="${channel}"]`);
+	if (pane) {
+		return pane;
+	}
+
+	pane = document.createElement("div");
+	pane.className = "pane";
+	pane.dataset.channel = channel;
+	$("panes").append(pane);
+
+	const tab = document.createElement("button");
+	tab.textContent = channel;
+	tab.dataset.channel = channel;
+	tab.onclick = () => showChannel(channel);
+	$("channels").append(tab);
+	showChannel(state.channel);
+
+	return pane;
+}
+
+function showChannel(channel) {
+	state.channel = channel;
+	for (const pane of document.querySelectorAll(".pane")) {
+		pane.hidden = pane.dataset.channel !== channel;
+	}
+	for (const tab of $("channels").children) {
+		tab.classList.toggle("current", tab.dataset.channel === channel);
+		if (tab.dataset.channel === channel) {
+			tab.classList.remove("unread");
+		}
+	}
+}
+
+function line(channel, from, text, className) {
+	const p = document.createElement("p");
+	if (className) {
+		p.className = className;
+	}
+	if (from) {
+		const name = document.createElement("span");
+		name.className = "from";
+		name.textContent = `${from}: `;
+		p.append(name);
+	}
+	p.append(text);
+	pane(channel).append(p);
+	$("panes").scrollTop = $("panes").scrollHeight;
+
+	if (channel !== state.channel) {
+		document.querySelector(`#channels button[data-channel="${channel}"]`).classList.add("unread");
+	}
+}
+
+function me() {
+	return state.players.find((p) => p.username === state.username);
+}
+
+// Returns the buttons the player may use on others right now.
+function available() {
+	const byRole = actions[state.phase] || {};
+	const player = me();
+	if (!player) {
+		return [];
+	}
+	// A dying hunter shoots from the grave, everyone else acts alive.
+	if (!player.alive && state.phase !== "huntershot") {
+		return [];
+	}
+
+	return byRole[state.role] || byRole["*"] || [];
+}
+
+function act(type, target) {
+	if (type !== "LinkLovers") {
+		send(type, { target });
+		return;
+	}
+
+	state.lovers = state.lovers.includes(target) ? state.lovers.filter((name) => name !== target) : [...state.lovers, target];
+	if (state.lovers.length === 2) {
+		send("LinkLovers", { first: state.lovers[0], second: state.lovers[1] });
+		state.lovers = [];
+	}
+	render();
+}
+
+function render() {
+	const list = $("player-list");
+	list.replaceChildren();
+	for (const player of state.players) {
+		const li = document.createElement("li");
+		li.classList.toggle("dead", !player.alive);
+		li.classList.toggle("picked", state.lovers.includes(player.username));
+
+		const presence = document.createElement("span");
+		presence.className = `presence ${player.presence}`;
+		presence.title = player.presence;
+		const name = document.createElement("span");
+		name.className = "name";
+		name.textContent = player.username + (player.username === state.username ? " (you)" : "");
+		li.append(presence, name);
+
+		if (player.alive) {
+			for (const [label, type] of available()) {
+				const button = document.createElement("button");
+				button.textContent = label;
+				button.onclick = () => act(type, player.username);
+				li.append(button);
+			}
+		}
+		list.append(li);
+	}
+
+	$("pass").hidden = state.phase !== "witchheal" || state.role !== "witch";
+	$("rematch").hidden = state.phase !== "end";
+	$("leave").hidden = state.phase !== "end" && state.phase !== "connect";
+}
+
+function tick() {
+	const left = Math.max(0, Math.round((state.deadline - Date.now()) / 1000));
+	$("countdown").textContent = state.deadline ? `${Math.floor(left / 60)}:${String(left % 60).padStart(2, "0")}` : "";
+}
+
+$("login").onsubmit = (event) => {
+	event.preventDefault();
+	state.username = $("username").value.trim();
+	$("login-error").textContent = "";
+	const join = () => send("Connect", { username: state.username, room: $("room").value.trim() });
+	if (state.socket && state.socket.readyState === WebSocket.OPEN) {
+		join();
+	} else {
+		connect(join);
+	}
+};
+
+$("say").onsubmit = (event) => {
+	event.preventDefault();
+	const text = $("text").value;
+	if (!text) {
+		return;
+	}
+	send("Message", { msg: text });
+	line(nightPhases.includes(state.phase) ? state.role : "town", state.username, text);
+	$("text").value = "";
+};
+
+$("pass").onclick = () => send("UseHeal");
+$("rematch").onclick = () => send("RequestRematch");
+$("leave").onclick = () => send("LeaveRoom");
+$("rooms").onclick = () => send("ListRooms");
+setInterval(tick, 500);
+
+// A player who reloads the page takes their seat back.
+if (localStorage.getItem("token")) {
+	state.username = localStorage.getItem("username") || "";
+	connect(reconnect);
+}